@@ -0,0 +1,277 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of every environment variable that overrides a configuration value
+const EnvPrefix = "GREMLIN_"
+
+// Config holds the complete server configuration
+type Config struct {
+	Server  ServerConfig  `yaml:"server"`
+	Modules ModulesConfig `yaml:"modules"`
+	Egress  EgressConfig  `yaml:"egress"`
+}
+
+// ServerConfig holds the settings of the HTTP server itself
+type ServerConfig struct {
+	Addresses []string       `yaml:"addresses"`
+	TLS       TLSConfig      `yaml:"tls"`
+	Shutdown  ShutdownConfig `yaml:"shutdown"`
+}
+
+// TLSConfig controls the HTTPS listeners. TLS is enabled when at least one address is given;
+// without a certificate and key file a self-signed certificate is generated at startup.
+type TLSConfig struct {
+	Addresses  []string         `yaml:"addresses"`
+	CertFile   string           `yaml:"certFile"`
+	KeyFile    string           `yaml:"keyFile"`
+	SelfSigned SelfSignedConfig `yaml:"selfSigned"`
+	// ClientAuth is one of none, request, require, verify-if-given or require-and-verify
+	ClientAuth   string `yaml:"clientAuth"`
+	ClientCAFile string `yaml:"clientCAFile"`
+	// MinVersion is one of 1.0, 1.1, 1.2 or 1.3
+	MinVersion string `yaml:"minVersion"`
+}
+
+// SelfSignedConfig controls the generated CA and leaf certificate
+type SelfSignedConfig struct {
+	Hosts    []string      `yaml:"hosts"`
+	ValidFor time.Duration `yaml:"validFor"`
+	// CACertFile and CAKeyFile are loaded when they exist and written after generating a CA otherwise,
+	// which keeps the CA stable across restarts and allows issuing client certificates with it
+	CACertFile string `yaml:"caCertFile"`
+	CAKeyFile  string `yaml:"caKeyFile"`
+}
+
+// Enabled reports whether any HTTPS listener is configured
+func (t *TLSConfig) Enabled() bool {
+	return len(t.Addresses) > 0
+}
+
+// UsesSelfSigned reports whether the serving certificate is generated rather than loaded from files
+func (t *TLSConfig) UsesSelfSigned() bool {
+	return t.CertFile == "" && t.KeyFile == ""
+}
+
+// ShutdownConfig controls how in-flight requests are drained when the server stops
+type ShutdownConfig struct {
+	// DrainDelay is how long readiness reports failing while new requests are still accepted
+	DrainDelay time.Duration `yaml:"drainDelay"`
+	// Timeout bounds how long in-flight requests may run once listeners are closed;
+	// requests still running afterwards have their contexts cancelled
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// EgressConfig restricts the destinations of outbound HTTP requests and database connections.
+// Deny rules win; when allowHosts or allowCidrs is given, a destination must match one of them.
+type EgressConfig struct {
+	// Schemes lists the URL schemes outbound HTTP requests may use; empty allows any
+	Schemes []string `yaml:"schemes"`
+	// AllowHosts and DenyHosts accept names such as api.example.com or *.example.com
+	AllowHosts []string `yaml:"allowHosts"`
+	DenyHosts  []string `yaml:"denyHosts"`
+	AllowCIDRs []string `yaml:"allowCidrs"`
+	DenyCIDRs  []string `yaml:"denyCidrs"`
+	// Ports lists the ports that may be dialed; empty allows any
+	Ports []int `yaml:"ports"`
+	// BlockLinkLocal denies link-local addresses and cloud metadata endpoints such as 169.254.169.254
+	BlockLinkLocal bool `yaml:"blockLinkLocal"`
+}
+
+// Enabled reports whether the policy restricts anything
+func (e *EgressConfig) Enabled() bool {
+	return len(e.Schemes) > 0 || len(e.AllowHosts) > 0 || len(e.DenyHosts) > 0 ||
+		len(e.AllowCIDRs) > 0 || len(e.DenyCIDRs) > 0 || len(e.Ports) > 0 || e.BlockLinkLocal
+}
+
+// Policy converts the settings into an egress policy
+func (e *EgressConfig) Policy() egress.Policy {
+	return egress.Policy{
+		Schemes:        e.Schemes,
+		AllowHosts:     e.AllowHosts,
+		DenyHosts:      e.DenyHosts,
+		AllowCIDRs:     e.AllowCIDRs,
+		DenyCIDRs:      e.DenyCIDRs,
+		Ports:          e.Ports,
+		BlockLinkLocal: e.BlockLinkLocal,
+	}
+}
+
+// ModuleConfig holds the settings shared by every handler module
+type ModuleConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Prefix  string `yaml:"prefix"`
+}
+
+// ModulesConfig holds the settings of each handler module
+type ModulesConfig struct {
+	Health     ModuleConfig           `yaml:"health"`
+	Metrics    ModuleConfig           `yaml:"metrics"`
+	About      ModuleConfig           `yaml:"about"`
+	Echo       EchoModuleConfig       `yaml:"echo"`
+	Load       LoadModuleConfig       `yaml:"load"`
+	HTTPSender HTTPSenderModuleConfig `yaml:"httpSender"`
+	PostgreSQL PostgreSQLModuleConfig `yaml:"postgresql"`
+	Mock       ModuleConfig           `yaml:"mock"`
+}
+
+// LoadModuleConfig holds the settings of the Load module
+type LoadModuleConfig struct {
+	ModuleConfig `yaml:",inline"`
+	// DiskDirectory is where disk load creates its test files; requests may only name its subdirectories
+	DiskDirectory string `yaml:"diskDirectory"`
+	// DiskMaxFileSize and DiskMaxBlockSize bound the test file and each read or write of disk load, in bytes
+	DiskMaxFileSize  int64 `yaml:"diskMaxFileSize"`
+	DiskMaxBlockSize int64 `yaml:"diskMaxBlockSize"`
+	// DiskMaxWorkers bounds the concurrent workers of disk load, each holding a block-sized buffer
+	DiskMaxWorkers int `yaml:"diskMaxWorkers"`
+}
+
+// EchoModuleConfig holds the settings of the Echo module
+type EchoModuleConfig struct {
+	ModuleConfig `yaml:",inline"`
+	// BinCapacity is how many requests a bin keeps when it is created without a capacity
+	BinCapacity int `yaml:"binCapacity"`
+	// BinMaxCapacity bounds the capacity a bin may be created with
+	BinMaxCapacity int `yaml:"binMaxCapacity"`
+	// BinMaxBodySize is how many bytes of each captured body are stored; longer bodies are truncated
+	BinMaxBodySize int64 `yaml:"binMaxBodySize"`
+}
+
+// HTTPSenderModuleConfig holds the settings of the HTTP forwarding module
+type HTTPSenderModuleConfig struct {
+	ModuleConfig `yaml:",inline"`
+	// MaxMonitors bounds how many synthetic monitors may be registered
+	MaxMonitors int `yaml:"maxMonitors"`
+	// MonitorHistory is how many checks each monitor keeps
+	MonitorHistory int `yaml:"monitorHistory"`
+	// MonitorMinInterval is the shortest interval a monitor may run at
+	MonitorMinInterval time.Duration `yaml:"monitorMinInterval"`
+}
+
+// PostgreSQLModuleConfig holds the settings of the PostgreSQL module
+type PostgreSQLModuleConfig struct {
+	ModuleConfig     `yaml:",inline"`
+	ConnectTimeout   time.Duration `yaml:"connectTimeout"`
+	QueryPingTimeout time.Duration `yaml:"queryPingTimeout"`
+}
+
+// ValidationError lists every problem found while loading or validating a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Default returns the configuration used when nothing else is specified
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addresses: []string{":8080"},
+			TLS: TLSConfig{
+				SelfSigned: SelfSignedConfig{
+					Hosts:    []string{"localhost", "127.0.0.1", "::1"},
+					ValidFor: 365 * 24 * time.Hour,
+				},
+				ClientAuth: "none",
+				MinVersion: "1.2",
+			},
+			Shutdown: ShutdownConfig{
+				DrainDelay: 0,
+				Timeout:    30 * time.Second,
+			},
+		},
+		Modules: ModulesConfig{
+			Health:  ModuleConfig{Enabled: true, Prefix: ""},
+			Metrics: ModuleConfig{Enabled: true, Prefix: "/metrics"},
+			About:   ModuleConfig{Enabled: true, Prefix: "/about"},
+			Echo: EchoModuleConfig{
+				ModuleConfig:   ModuleConfig{Enabled: true, Prefix: "/echo"},
+				BinCapacity:    100,
+				BinMaxCapacity: 10000,
+				BinMaxBodySize: 1 << 20,
+			},
+			Load: LoadModuleConfig{
+				ModuleConfig:     ModuleConfig{Enabled: true, Prefix: "/load"},
+				DiskDirectory:    os.TempDir(),
+				DiskMaxFileSize:  1 << 30,
+				DiskMaxBlockSize: 16 << 20,
+				DiskMaxWorkers:   64,
+			},
+			HTTPSender: HTTPSenderModuleConfig{
+				ModuleConfig:       ModuleConfig{Enabled: true, Prefix: "/http-send"},
+				MaxMonitors:        100,
+				MonitorHistory:     1000,
+				MonitorMinInterval: time.Second,
+			},
+			PostgreSQL: PostgreSQLModuleConfig{
+				ModuleConfig:     ModuleConfig{Enabled: true, Prefix: "/postgresql"},
+				ConnectTimeout:   10 * time.Second,
+				QueryPingTimeout: 5 * time.Second,
+			},
+			Mock: ModuleConfig{Enabled: true, Prefix: "/mock"},
+		},
+		Egress: EgressConfig{
+			BlockLinkLocal: true,
+		},
+	}
+}
+
+// Load builds a configuration from the defaults, the given file (if any) and GREMLIN_* environment variables,
+// in increasing order of precedence. The result is not validated; call Validate once all overrides are applied.
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	var problems []string
+
+	if path != "" {
+		problems = append(problems, decodeFile(path, cfg)...)
+	}
+
+	problems = append(problems, applyEnv(cfg, os.Environ())...)
+
+	if len(problems) > 0 {
+		return nil, &ValidationError{Problems: problems}
+	}
+	return cfg, nil
+}
+
+// decodeFile reads a YAML or JSON file into cfg, reporting unknown keys and type mismatches
+func decodeFile(path string, cfg *Config) []string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []string{fmt.Sprintf("reading config file: %v", err)}
+	}
+
+	// JSON is a subset of YAML, so a single decoder handles both formats
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			problems := make([]string, 0, len(typeErr.Errors))
+			for _, e := range typeErr.Errors {
+				problems = append(problems, fmt.Sprintf("%s: %s", path, e))
+			}
+			return problems
+		}
+		if errors.Is(err, io.EOF) {
+			// An empty file keeps the defaults
+			return nil
+		}
+		return []string{fmt.Sprintf("%s: %v", path, err)}
+	}
+	return nil
+}
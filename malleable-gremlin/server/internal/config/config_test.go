@@ -0,0 +1,245 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatalf("default configuration is invalid: %v", err)
+	}
+}
+
+func TestEnvSegment(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"prefix", "PREFIX"},
+		{"connectTimeout", "CONNECT_TIMEOUT"},
+		{"httpSender", "HTTP_SENDER"},
+		{"allowCidrs", "ALLOW_CIDRS"},
+		{"caCertFile", "CA_CERT_FILE"},
+		{"clientCAFile", "CLIENT_CA_FILE"},
+		{"TLS", "TLS"},
+		{"v2Api", "V2_API"},
+	}
+	for _, tt := range tests {
+		if got := envSegment(tt.key); got != tt.want {
+			t.Errorf("envSegment(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		environ  []string
+		check    func(*Config) interface{}
+		want     interface{}
+		problems int
+	}{
+		{
+			name:    "string",
+			environ: []string{"GREMLIN_MODULES_ECHO_PREFIX=/mirror"},
+			check:   func(c *Config) interface{} { return c.Modules.Echo.Prefix },
+			want:    "/mirror",
+		},
+		{
+			name:    "bool",
+			environ: []string{"GREMLIN_MODULES_MOCK_ENABLED=false"},
+			check:   func(c *Config) interface{} { return c.Modules.Mock.Enabled },
+			want:    false,
+		},
+		{
+			name:    "duration",
+			environ: []string{"GREMLIN_MODULES_POSTGRESQL_CONNECT_TIMEOUT=3s"},
+			check:   func(c *Config) interface{} { return c.Modules.PostgreSQL.ConnectTimeout },
+			want:    3 * time.Second,
+		},
+		{
+			name:    "integer",
+			environ: []string{"GREMLIN_MODULES_HTTP_SENDER_MONITOR_HISTORY=50"},
+			check:   func(c *Config) interface{} { return c.Modules.HTTPSender.MonitorHistory },
+			want:    50,
+		},
+		{
+			name:    "string list",
+			environ: []string{"GREMLIN_SERVER_ADDRESSES=:8080, :9090,"},
+			check:   func(c *Config) interface{} { return c.Server.Addresses },
+			want:    []string{":8080", ":9090"},
+		},
+		{
+			name:    "integer list",
+			environ: []string{"GREMLIN_EGRESS_PORTS=80,443"},
+			check:   func(c *Config) interface{} { return c.Egress.Ports },
+			want:    []int{80, 443},
+		},
+		{
+			name:    "nested struct",
+			environ: []string{"GREMLIN_SERVER_TLS_SELF_SIGNED_CA_CERT_FILE=/tmp/ca.pem"},
+			check:   func(c *Config) interface{} { return c.Server.TLS.SelfSigned.CACertFile },
+			want:    "/tmp/ca.pem",
+		},
+		{
+			name:    "unknown variables are ignored",
+			environ: []string{"GREMLIN_SERVICE_HOST=10.0.0.1", "GREMLIN_PORT=tcp://10.0.0.1:80", "OTHER=1"},
+			check:   func(c *Config) interface{} { return c.Server.Addresses },
+			want:    []string{":8080"},
+		},
+		{
+			name:    "config file variable is not a field",
+			environ: []string{"GREMLIN_CONFIG=/etc/gremlin.yaml"},
+			check:   func(c *Config) interface{} { return c.Server.Addresses },
+			want:    []string{":8080"},
+		},
+		{
+			name:     "malformed values are reported",
+			environ:  []string{"GREMLIN_MODULES_MOCK_ENABLED=maybe", "GREMLIN_EGRESS_PORTS=80,http", "GREMLIN_SERVER_SHUTDOWN_TIMEOUT=soon"},
+			problems: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			problems := applyEnv(cfg, tt.environ)
+			if len(problems) != tt.problems {
+				t.Fatalf("got problems %q, want %d", problems, tt.problems)
+			}
+			if tt.check == nil {
+				return
+			}
+			if got := tt.check(cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadFileThenEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gremlin.yaml")
+	data := "modules:\n  echo:\n    prefix: /from-file\n    binCapacity: 7\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GREMLIN_MODULES_ECHO_PREFIX", "/from-env")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Modules.Echo.Prefix != "/from-env" {
+		t.Errorf("prefix = %q, want the environment to win", cfg.Modules.Echo.Prefix)
+	}
+	if cfg.Modules.Echo.BinCapacity != 7 {
+		t.Errorf("binCapacity = %d, want 7 from the file", cfg.Modules.Echo.BinCapacity)
+	}
+	if cfg.Modules.Echo.BinMaxCapacity != 10000 {
+		t.Errorf("binMaxCapacity = %d, want the default", cfg.Modules.Echo.BinMaxCapacity)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "gremlin.yaml")
+	if err := os.WriteFile(path, []byte("modules:\n  echo:\n    prefx: /typo\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Load(path)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("got %v, want a ValidationError", err)
+	}
+	if !strings.Contains(err.Error(), "prefx") {
+		t.Errorf("error %q does not name the unknown key", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{
+			name:   "no listener",
+			modify: func(c *Config) { c.Server.Addresses = nil },
+			want:   "server.addresses: at least one listen address",
+		},
+		{
+			name:   "bad port",
+			modify: func(c *Config) { c.Server.Addresses = []string{":99999"} },
+			want:   "server.addresses[0]: invalid port",
+		},
+		{
+			name:   "duplicate address",
+			modify: func(c *Config) { c.Server.TLS.Addresses = []string{":8080"} },
+			want:   "server.tls.addresses[0]: duplicate address",
+		},
+		{
+			name:   "duplicate prefix",
+			modify: func(c *Config) { c.Modules.Mock.Prefix = "/echo" },
+			want:   `modules.mock.prefix: "/echo" is already used by modules.echo`,
+		},
+		{
+			name:   "empty prefix of a module that cannot be mounted at the root",
+			modify: func(c *Config) { c.Modules.Metrics.Prefix = "" },
+			want:   "modules.metrics.prefix: must not be empty",
+		},
+		{
+			name:   "prefix without a leading slash",
+			modify: func(c *Config) { c.Modules.Echo.Prefix = "echo" },
+			want:   "modules.echo.prefix: must start with '/'",
+		},
+		{
+			name:   "bin capacity above the maximum",
+			modify: func(c *Config) { c.Modules.Echo.BinCapacity = c.Modules.Echo.BinMaxCapacity + 1 },
+			want:   "modules.echo.binCapacity",
+		},
+		{
+			name:   "non-positive disk limit",
+			modify: func(c *Config) { c.Modules.Load.DiskMaxWorkers = 0 },
+			want:   "modules.load.diskMaxWorkers: must be positive",
+		},
+		{
+			name:   "unknown client auth",
+			modify: func(c *Config) { c.Server.TLS.Addresses = []string{":8443"}; c.Server.TLS.ClientAuth = "sometimes" },
+			want:   "server.tls.clientAuth: unknown mode",
+		},
+		{
+			name:   "invalid egress CIDR",
+			modify: func(c *Config) { c.Egress.DenyCIDRs = []string{"10.0.0.0/33"} },
+			want:   "egress:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil {
+				t.Fatalf("got no error, want %q", tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateDisabledModuleIsNotChecked(t *testing.T) {
+	cfg := Default()
+	cfg.Modules.Metrics.Enabled = false
+	cfg.Modules.Metrics.Prefix = ""
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("got %v, want disabled modules to be skipped", err)
+	}
+}
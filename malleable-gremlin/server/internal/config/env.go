@@ -0,0 +1,153 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// EnvConfigFile names the environment variable holding the config file path
+const EnvConfigFile = EnvPrefix + "CONFIG"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv overrides cfg with GREMLIN_* variables from environ. Variable names are derived from the
+// YAML keys, e.g. modules.postgresql.connectTimeout becomes GREMLIN_MODULES_POSTGRESQL_CONNECT_TIMEOUT.
+// Lists are given as comma-separated values. Variables matching no field are only logged, since the
+// environment may hold GREMLIN_* variables set by others, e.g. Kubernetes service links for a Service
+// named gremlin (GREMLIN_SERVICE_HOST, GREMLIN_PORT, ...).
+func applyEnv(cfg *Config, environ []string) []string {
+	fields := make(map[string]reflect.Value)
+	collectEnvFields(reflect.ValueOf(cfg).Elem(), strings.TrimSuffix(EnvPrefix, "_"), fields)
+
+	var problems []string
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, EnvPrefix) || name == EnvConfigFile {
+			continue
+		}
+
+		field, ok := fields[name]
+		if !ok {
+			log.Printf("Ignoring %s: unknown configuration variable", name)
+			continue
+		}
+
+		if err := setFromString(field, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", name, err))
+		}
+	}
+
+	sort.Strings(problems)
+	return problems
+}
+
+// collectEnvFields maps the environment variable name of every settable leaf field below v
+func collectEnvFields(v reflect.Value, name string, fields map[string]reflect.Value) {
+	if v.Kind() != reflect.Struct || v.Type() == durationType {
+		fields[name] = v
+		return
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key, opts, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+		if key == "-" || !sf.IsExported() {
+			continue
+		}
+
+		if opts == "inline" {
+			collectEnvFields(v.Field(i), name, fields)
+			continue
+		}
+		if key == "" {
+			key = sf.Name
+		}
+		collectEnvFields(v.Field(i), name+"_"+envSegment(key), fields)
+	}
+}
+
+// envSegment converts a camelCase YAML key into an UPPER_SNAKE_CASE name segment
+func envSegment(key string) string {
+	runes := []rune(key)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// setFromString parses value into the field according to its type
+func setFromString(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+
+		switch field.Type().Elem().Kind() {
+		case reflect.String:
+			field.Set(reflect.ValueOf(items))
+		case reflect.Int:
+			numbers := make([]int, 0, len(items))
+			for _, item := range items {
+				n, err := strconv.Atoi(item)
+				if err != nil {
+					return fmt.Errorf("invalid integer %q", item)
+				}
+				numbers = append(numbers, n)
+			}
+			field.Set(reflect.ValueOf(numbers))
+		default:
+			return fmt.Errorf("cannot be set from the environment")
+		}
+	default:
+		return fmt.Errorf("cannot be set from the environment")
+	}
+	return nil
+}
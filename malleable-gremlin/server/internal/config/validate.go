@@ -0,0 +1,209 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+)
+
+// Validate checks the whole configuration and reports every problem found
+func (c *Config) Validate() error {
+	var problems []string
+	addProblem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(c.Server.Addresses) == 0 && !c.Server.TLS.Enabled() {
+		addProblem("server.addresses: at least one listen address is required in server.addresses or server.tls.addresses")
+	}
+	seenAddrs := make(map[string]bool)
+	checkAddresses := func(key string, addrs []string) {
+		for i, addr := range addrs {
+			if err := validateAddress(addr); err != nil {
+				addProblem("%s[%d]: %v", key, i, err)
+			}
+			if seenAddrs[addr] {
+				addProblem("%s[%d]: duplicate address %q", key, i, addr)
+			}
+			seenAddrs[addr] = true
+		}
+	}
+	checkAddresses("server.addresses", c.Server.Addresses)
+	checkAddresses("server.tls.addresses", c.Server.TLS.Addresses)
+
+	if c.Server.TLS.Enabled() {
+		problems = append(problems, validateTLS(&c.Server.TLS)...)
+	}
+
+	if c.Server.Shutdown.DrainDelay < 0 {
+		addProblem("server.shutdown.drainDelay: must not be negative")
+	}
+	if c.Server.Shutdown.Timeout <= 0 {
+		addProblem("server.shutdown.timeout: must be positive")
+	}
+
+	seenPrefixes := make(map[string]string)
+	for _, m := range c.Modules.list() {
+		if !m.config.Enabled {
+			continue
+		}
+		// An empty prefix mounts the module's routes at the root, which only some modules support
+		if m.config.Prefix == "" {
+			if !m.rootable {
+				addProblem("modules.%s.prefix: must not be empty", m.name)
+			}
+			continue
+		}
+		if err := validatePrefix(m.config.Prefix); err != nil {
+			addProblem("modules.%s.prefix: %v", m.name, err)
+			continue
+		}
+		if other, ok := seenPrefixes[m.config.Prefix]; ok {
+			addProblem("modules.%s.prefix: %q is already used by modules.%s", m.name, m.config.Prefix, other)
+			continue
+		}
+		seenPrefixes[m.config.Prefix] = m.name
+	}
+
+	echo := c.Modules.Echo
+	if echo.BinMaxCapacity <= 0 {
+		addProblem("modules.echo.binMaxCapacity: must be positive")
+	}
+	if echo.BinCapacity <= 0 || echo.BinCapacity > echo.BinMaxCapacity {
+		addProblem("modules.echo.binCapacity: must be positive and at most binMaxCapacity")
+	}
+	if echo.BinMaxBodySize < 0 {
+		addProblem("modules.echo.binMaxBodySize: must not be negative")
+	}
+
+	sender := c.Modules.HTTPSender
+	if sender.MaxMonitors < 0 {
+		addProblem("modules.httpSender.maxMonitors: must not be negative")
+	}
+	if sender.MonitorHistory <= 0 {
+		addProblem("modules.httpSender.monitorHistory: must be positive")
+	}
+	if sender.MonitorMinInterval <= 0 {
+		addProblem("modules.httpSender.monitorMinInterval: must be positive")
+	}
+
+	loadModule := c.Modules.Load
+	if loadModule.DiskDirectory == "" {
+		addProblem("modules.load.diskDirectory: must not be empty")
+	}
+	if loadModule.DiskMaxFileSize <= 0 {
+		addProblem("modules.load.diskMaxFileSize: must be positive")
+	}
+	if loadModule.DiskMaxBlockSize <= 0 {
+		addProblem("modules.load.diskMaxBlockSize: must be positive")
+	}
+	if loadModule.DiskMaxWorkers <= 0 {
+		addProblem("modules.load.diskMaxWorkers: must be positive")
+	}
+
+	pg := c.Modules.PostgreSQL
+	if pg.ConnectTimeout <= 0 {
+		addProblem("modules.postgresql.connectTimeout: must be positive")
+	}
+	if pg.QueryPingTimeout <= 0 {
+		addProblem("modules.postgresql.queryPingTimeout: must be positive")
+	}
+
+	if _, err := egress.NewGuard(c.Egress.Policy()); err != nil {
+		addProblem("egress: %v", err)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+type namedModule struct {
+	name   string
+	config ModuleConfig
+	// rootable modules have no route at the bare prefix, so they can be mounted with an empty one
+	rootable bool
+}
+
+// list returns the shared settings of every module in a fixed order
+func (m *ModulesConfig) list() []namedModule {
+	return []namedModule{
+		{"health", m.Health, true},
+		{"metrics", m.Metrics, false},
+		{"about", m.About, false},
+		{"echo", m.Echo.ModuleConfig, false},
+		{"load", m.Load.ModuleConfig, false},
+		{"httpSender", m.HTTPSender.ModuleConfig, false},
+		{"postgresql", m.PostgreSQL.ModuleConfig, false},
+		{"mock", m.Mock, false},
+	}
+}
+
+// validateTLS checks the HTTPS listener settings
+func validateTLS(t *TLSConfig) []string {
+	var problems []string
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		problems = append(problems, "server.tls: certFile and keyFile must be given together")
+	}
+	if t.UsesSelfSigned() {
+		if len(t.SelfSigned.Hosts) == 0 {
+			problems = append(problems, "server.tls.selfSigned.hosts: at least one host is required")
+		}
+		if t.SelfSigned.ValidFor <= 0 {
+			problems = append(problems, "server.tls.selfSigned.validFor: must be positive")
+		}
+		if (t.SelfSigned.CACertFile == "") != (t.SelfSigned.CAKeyFile == "") {
+			problems = append(problems, "server.tls.selfSigned: caCertFile and caKeyFile must be given together")
+		}
+	}
+
+	switch t.ClientAuth {
+	case "none", "request", "require":
+	case "verify-if-given", "require-and-verify":
+		if t.ClientCAFile == "" && !t.UsesSelfSigned() {
+			problems = append(problems, fmt.Sprintf("server.tls.clientCAFile: required for clientAuth %q unless the self-signed CA is used", t.ClientAuth))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("server.tls.clientAuth: unknown mode %q, expected none, request, require, verify-if-given or require-and-verify", t.ClientAuth))
+	}
+
+	switch t.MinVersion {
+	case "1.0", "1.1", "1.2", "1.3":
+	default:
+		problems = append(problems, fmt.Sprintf("server.tls.minVersion: unknown version %q, expected 1.0, 1.1, 1.2 or 1.3", t.MinVersion))
+	}
+
+	return problems
+}
+
+// validateAddress checks that addr is a host:port pair with a valid port
+func validateAddress(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %v", addr, err)
+	}
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 0 || n > 65535 {
+		return fmt.Errorf("invalid port in listen address %q", addr)
+	}
+	return nil
+}
+
+// validatePrefix checks that a route prefix can be combined with the module's route patterns
+func validatePrefix(prefix string) error {
+	if !strings.HasPrefix(prefix, "/") {
+		return fmt.Errorf("must start with '/', got %q", prefix)
+	}
+	if strings.HasSuffix(prefix, "/") {
+		return fmt.Errorf("must not end with '/', got %q", prefix)
+	}
+	if strings.ContainsAny(prefix, "{} \t") {
+		return fmt.Errorf("must not contain wildcards or whitespace, got %q", prefix)
+	}
+	return nil
+}
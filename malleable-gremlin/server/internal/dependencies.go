@@ -1,29 +1,89 @@
 package internal
 
 import (
+	"log"
+
+	"github.com/eyadmba/malleable-gremlin/server/internal/config"
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+	"github.com/eyadmba/malleable-gremlin/services/health"
+	"github.com/eyadmba/malleable-gremlin/services/httpsender"
+	"github.com/eyadmba/malleable-gremlin/services/load"
+	"github.com/eyadmba/malleable-gremlin/services/mock"
+	"github.com/eyadmba/malleable-gremlin/services/monitor"
 	"github.com/eyadmba/malleable-gremlin/services/postgresql"
+	"github.com/eyadmba/malleable-gremlin/services/requestbin"
 )
 
 // Dependencies holds all the dependencies needed by the server
 type Dependencies struct {
+	Health          *health.Status
+	LoadJobs        *load.JobManager
+	MemoryHolder    *load.MemoryHolder
+	DiskLoad        load.DiskConfig
 	PostgresManager *postgresql.ConnectionManager
+	RequestBins     *requestbin.Manager
+	MockServer      *mock.Server
+	Monitors        *monitor.Manager
 	// Add more dependencies here as needed
 }
 
 // BuildDependencies creates and initializes all required dependencies
-func BuildDependencies() *Dependencies {
+func BuildDependencies(cfg *config.Config) *Dependencies {
+	// Restrict outbound connections; the policy was checked by Validate
+	var guard *egress.Guard
+	if cfg.Egress.Enabled() {
+		var err error
+		if guard, err = egress.NewGuard(cfg.Egress.Policy()); err != nil {
+			log.Fatalf("egress policy: %v", err)
+		}
+		httpsender.SetEgressGuard(guard)
+	}
+
 	// Create PostgreSQL connection manager
-	pgManager := postgresql.NewConnectionManager()
+	pgManager := postgresql.NewConnectionManager(postgresql.Config{
+		ConnectTimeout:   cfg.Modules.PostgreSQL.ConnectTimeout,
+		QueryPingTimeout: cfg.Modules.PostgreSQL.QueryPingTimeout,
+		Egress:           guard,
+	})
 
 	// Return all dependencies
 	return &Dependencies{
+		Health:          health.NewStatus(),
+		LoadJobs:        load.NewJobManager(),
+		MemoryHolder:    load.NewMemoryHolder(),
 		PostgresManager: pgManager,
+		DiskLoad: load.DiskConfig{
+			Directory:    cfg.Modules.Load.DiskDirectory,
+			MaxFileSize:  cfg.Modules.Load.DiskMaxFileSize,
+			MaxBlockSize: cfg.Modules.Load.DiskMaxBlockSize,
+			MaxWorkers:   cfg.Modules.Load.DiskMaxWorkers,
+		},
+		RequestBins: requestbin.NewManager(requestbin.Config{
+			DefaultCapacity: cfg.Modules.Echo.BinCapacity,
+			MaxCapacity:     cfg.Modules.Echo.BinMaxCapacity,
+			MaxBodySize:     cfg.Modules.Echo.BinMaxBodySize,
+		}),
+		MockServer: mock.NewServer(),
+		Monitors: monitor.NewManager(monitor.Config{
+			MaxMonitors: cfg.Modules.HTTPSender.MaxMonitors,
+			History:     cfg.Modules.HTTPSender.MonitorHistory,
+			MinInterval: cfg.Modules.HTTPSender.MonitorMinInterval,
+		}),
 		// Add more dependencies here as needed
 	}
 }
 
 // Close closes all resources held by dependencies
 func (d *Dependencies) Close() {
+	if d.LoadJobs != nil {
+		d.LoadJobs.Close()
+	}
+	if d.Monitors != nil {
+		d.Monitors.Close()
+	}
+	if d.MemoryHolder != nil {
+		d.MemoryHolder.Close()
+	}
 	if d.PostgresManager != nil {
 		d.PostgresManager.Close()
 	}
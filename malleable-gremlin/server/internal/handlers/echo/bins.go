@@ -0,0 +1,159 @@
+package echo
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+	"github.com/eyadmba/malleable-gremlin/services/httpsender"
+	"github.com/eyadmba/malleable-gremlin/services/requestbin"
+)
+
+type createBinRequest struct {
+	Name     string `json:"name"`
+	Capacity int    `json:"capacity"`
+}
+
+// handleCapture returns a handler storing the request in its bin. The acknowledgement honours the
+// same shaping parameters as the other echo endpoints, so a bin can stand in for a failing webhook.
+func handleCapture(bins *requestbin.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		shape, err := parseShape(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		captured, err := bins.Capture(r.PathValue("name"), r.PathValue("path"), r)
+		if errors.Is(err, requestbin.ErrBinNotFound) {
+			writeBinError(w, err)
+			return
+		} else if err != nil {
+			http.Error(w, "failed to read request body", http.StatusInternalServerError)
+			return
+		}
+
+		shape.write(w, r, map[string]interface{}{
+			"bin": r.PathValue("name"),
+			"id":  captured.ID,
+		})
+	}
+}
+
+// handleCreateBin returns a handler creating an empty bin
+func handleCreateBin(bins *requestbin.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req createBinRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		info, err := bins.Create(req.Name, req.Capacity)
+		if err != nil {
+			writeBinError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, info)
+	}
+}
+
+// handleListBins returns a handler listing every bin
+func handleListBins(bins *requestbin.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, bins.List())
+	}
+}
+
+// handleDeleteBin returns a handler removing a bin with its captured requests
+func handleDeleteBin(bins *requestbin.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := bins.Delete(r.PathValue("name")); err != nil {
+			writeBinError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleListCaptured returns a handler listing the requests of a bin, most recent first
+func handleListCaptured(bins *requestbin.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requests, err := bins.Requests(r.PathValue("name"))
+		if err != nil {
+			writeBinError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, requests)
+	}
+}
+
+// handleClearBin returns a handler removing the stored requests of a bin
+func handleClearBin(bins *requestbin.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := bins.Clear(r.PathValue("name"))
+		if err != nil {
+			writeBinError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	}
+}
+
+// handleGetCaptured returns a handler reporting one captured request
+func handleGetCaptured(bins *requestbin.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		captured, err := bins.Get(r.PathValue("name"), r.PathValue("id"))
+		if err != nil {
+			writeBinError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, captured)
+	}
+}
+
+// handleReplay returns a handler sending a captured request to another URL
+func handleReplay(bins *requestbin.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var arg requestbin.ReplayArgument
+		if err := json.NewDecoder(r.Body).Decode(&arg); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		result, err := bins.Replay(r.Context(), r.PathValue("name"), r.PathValue("id"), &arg)
+		if err != nil {
+			writeBinError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+// writeBinError maps request bin errors to HTTP status codes; anything else is a failed replay
+func writeBinError(w http.ResponseWriter, err error) {
+	if egress.WriteDenied(w, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, requestbin.ErrBinNotFound), errors.Is(err, requestbin.ErrRequestNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, requestbin.ErrBinExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, requestbin.ErrInvalidBinName), errors.Is(err, requestbin.ErrInvalidCapacity),
+		errors.Is(err, requestbin.ErrInvalidReplay), errors.Is(err, httpsender.ErrInvalidSendArgument):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, requestbin.ErrReplayUnsupported):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
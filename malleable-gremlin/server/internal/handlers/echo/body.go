@@ -0,0 +1,120 @@
+package echo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FileInfo describes a file uploaded in a multipart body
+type FileInfo struct {
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	SHA256      string `json:"sha256"`
+}
+
+// bodyInfo is the decoded content of a request body
+type bodyInfo struct {
+	Data  string
+	Size  int64
+	Form  url.Values
+	Files []FileInfo
+	JSON  interface{}
+	// Error reports a malformed or truncated body; whatever was decoded before it is kept
+	Error string
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// readBody decodes the request body according to its content type. Multipart bodies are streamed
+// so uploads of any size are hashed without being held in memory; other bodies are kept raw.
+func readBody(r *http.Request) (*bodyInfo, error) {
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	body := &countingReader{reader: r.Body}
+	info := &bodyInfo{}
+
+	if mediaType == "multipart/form-data" {
+		info.Form = make(url.Values)
+		info.Files = make([]FileInfo, 0)
+		if err := readMultipart(multipart.NewReader(body, params["boundary"]), info); err != nil {
+			info.Error = err.Error()
+		}
+		// Drain any epilogue so the reported size is the full body
+		io.Copy(io.Discard, body)
+		info.Size = body.n
+		return info, nil
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	info.Data = string(data)
+	info.Size = body.n
+
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		if info.Form, err = url.ParseQuery(info.Data); err != nil {
+			info.Error = err.Error()
+		}
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if err := json.Unmarshal(data, &info.JSON); err != nil {
+			info.Error = "invalid JSON body: " + err.Error()
+		}
+	}
+	return info, nil
+}
+
+// readMultipart collects the form fields and file digests of a multipart body
+func readMultipart(reader *multipart.Reader, info *bodyInfo) error {
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if part.FileName() == "" {
+			value, err := io.ReadAll(part)
+			if err != nil {
+				return err
+			}
+			info.Form.Add(part.FormName(), string(value))
+			continue
+		}
+
+		hash := sha256.New()
+		size, err := io.Copy(hash, part)
+		if err != nil {
+			return err
+		}
+		info.Files = append(info.Files, FileInfo{
+			Field:       part.FormName(),
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get("Content-Type"),
+			Size:        size,
+			SHA256:      hex.EncodeToString(hash.Sum(nil)),
+		})
+	}
+}
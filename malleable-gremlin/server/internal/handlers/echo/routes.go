@@ -1,25 +1,39 @@
 package echo
 
 import (
-	"encoding/json"
 	"io"
 	"net/http"
-	"strconv"
+
+	"github.com/eyadmba/malleable-gremlin/services/requestbin"
 )
 
 // SetupRoutes configures routes for the Echo service
-func SetupRoutes(prefix string, router *http.ServeMux) {
+func SetupRoutes(prefix string, router *http.ServeMux, bins *requestbin.Manager) {
 	router.HandleFunc("GET "+prefix+"/get", handleGetEcho)
 	router.HandleFunc("POST "+prefix+"/post", handlePostEcho)
+
+	// Patterns without a method match every method, including custom verbs
+	router.HandleFunc(prefix+"/anything", handleAnyEcho)
+	router.HandleFunc(prefix+"/anything/{path...}", handleAnyEcho)
+
+	// Request bins capture every method and path below /bin/{name}
+	router.HandleFunc(prefix+"/bin/{name}", handleCapture(bins))
+	router.HandleFunc(prefix+"/bin/{name}/{path...}", handleCapture(bins))
+	router.HandleFunc("POST "+prefix+"/bins", handleCreateBin(bins))
+	router.HandleFunc("GET "+prefix+"/bins", handleListBins(bins))
+	router.HandleFunc("DELETE "+prefix+"/bins/{name}", handleDeleteBin(bins))
+	router.HandleFunc("GET "+prefix+"/bins/{name}/requests", handleListCaptured(bins))
+	router.HandleFunc("DELETE "+prefix+"/bins/{name}/requests", handleClearBin(bins))
+	router.HandleFunc("GET "+prefix+"/bins/{name}/requests/{id}", handleGetCaptured(bins))
+	router.HandleFunc("POST "+prefix+"/bins/{name}/requests/{id}/replay", handleReplay(bins))
 }
 
 // handleGetEcho echoes back request details for GET requests
 func handleGetEcho(w http.ResponseWriter, r *http.Request) {
-	status := 200
-	if statusParam := r.URL.Query().Get("status"); statusParam != "" {
-		if s, err := strconv.Atoi(statusParam); err == nil {
-			status = s
-		}
+	shape, err := parseShape(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	response := map[string]interface{}{
@@ -27,19 +41,53 @@ func handleGetEcho(w http.ResponseWriter, r *http.Request) {
 		"headers": r.Header,
 		"url":     r.URL.String(),
 	}
+	if info := tlsInfo(r); info != nil {
+		response["tls"] = info
+	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(response)
+	shape.write(w, r, response)
 }
 
-// handlePostEcho echoes back request details for POST requests
+// handlePostEcho echoes back request details for POST requests, decoding form, multipart and JSON bodies
 func handlePostEcho(w http.ResponseWriter, r *http.Request) {
-	status := 200
-	if statusParam := r.URL.Query().Get("status"); statusParam != "" {
-		if s, err := strconv.Atoi(statusParam); err == nil {
-			status = s
-		}
+	shape, err := parseShape(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, "failed to read request body", http.StatusInternalServerError)
+		return
+	}
+
+	response := map[string]interface{}{
+		"args":      r.URL.Query(),
+		"headers":   r.Header,
+		"url":       r.URL.String(),
+		"form":      body.Form,
+		"files":     body.Files,
+		"data":      body.Data,
+		"json":      body.JSON,
+		"body_size": body.Size,
+	}
+	if body.Error != "" {
+		response["error"] = body.Error
+	}
+	if info := tlsInfo(r); info != nil {
+		response["tls"] = info
+	}
+
+	shape.write(w, r, response)
+}
+
+// handleAnyEcho echoes back request details for any method and any path below /anything
+func handleAnyEcho(w http.ResponseWriter, r *http.Request) {
+	shape, err := parseShape(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	body, err := io.ReadAll(r.Body)
@@ -49,15 +97,24 @@ func handlePostEcho(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response := map[string]interface{}{
-		"args":    r.URL.Query(),
-		"headers": r.Header,
-		"url":     r.URL.String(),
-		"form":    r.PostForm,
-		"files":   r.MultipartForm,
-		"data":    string(body),
+		"method":            r.Method,
+		"path":              r.URL.Path,
+		"raw_path":          r.URL.EscapedPath(),
+		"request_uri":       r.RequestURI,
+		"args":              r.URL.Query(),
+		"headers":           r.Header,
+		"proto":             r.Proto,
+		"host":              r.Host,
+		"remote_addr":       r.RemoteAddr,
+		"content_length":    r.ContentLength,
+		"transfer_encoding": r.TransferEncoding,
+		"trailers":          r.Trailer,
+		"url":               r.URL.String(),
+		"data":              string(body),
+	}
+	if info := tlsInfo(r); info != nil {
+		response["tls"] = info
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	json.NewEncoder(w).Encode(response)
+	shape.write(w, r, response)
 }
@@ -0,0 +1,242 @@
+package echo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shapeHeaderPrefix is the prefix of request headers that shape the response like the query parameters do
+const shapeHeaderPrefix = "X-Gremlin-"
+
+// defaultChunkSize is the chunk size of streamed responses when only a rate is given
+const defaultChunkSize = 1024
+
+type weightedStatus struct {
+	code   int
+	weight float64
+}
+
+// responseShape controls how an echo response is delivered
+type responseShape struct {
+	statuses    []weightedStatus
+	headers     http.Header
+	body        *string
+	contentType string
+	delay       time.Duration
+	chunkSize   int
+	rate        int
+	compress    string
+}
+
+// shapeParam returns a shaping parameter from the query string or, failing that, the matching X-Gremlin-* header
+func shapeParam(r *http.Request, name string) string {
+	if value := r.URL.Query().Get(name); value != "" {
+		return value
+	}
+	return r.Header.Get(shapeHeaderPrefix + strings.ReplaceAll(name, "_", "-"))
+}
+
+// parseShape reads the response shaping parameters of a request
+func parseShape(r *http.Request) (*responseShape, error) {
+	shape := &responseShape{
+		statuses: []weightedStatus{{code: http.StatusOK, weight: 1}},
+		headers:  make(http.Header),
+	}
+
+	if value := shapeParam(r, "status"); value != "" {
+		statuses, err := parseStatuses(value)
+		if err != nil {
+			return nil, err
+		}
+		shape.statuses = statuses
+	}
+
+	headerValues := r.URL.Query()["response_header"]
+	headerValues = append(headerValues, r.Header.Values(shapeHeaderPrefix+"Response-Header")...)
+	for _, value := range headerValues {
+		name, headerValue, found := strings.Cut(value, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("invalid response_header %q, expected Name:value", value)
+		}
+		shape.headers.Add(strings.TrimSpace(name), strings.TrimSpace(headerValue))
+	}
+
+	if r.URL.Query().Has("body") {
+		body := r.URL.Query().Get("body")
+		shape.body = &body
+	} else if values := r.Header.Values(shapeHeaderPrefix + "Body"); len(values) > 0 {
+		shape.body = &values[0]
+	}
+	shape.contentType = shapeParam(r, "content_type")
+
+	var err error
+	if value := shapeParam(r, "delay"); value != "" {
+		if shape.delay, err = time.ParseDuration(value); err != nil || shape.delay < 0 {
+			return nil, fmt.Errorf("invalid delay format")
+		}
+	}
+	if value := shapeParam(r, "chunk_size"); value != "" {
+		if shape.chunkSize, err = strconv.Atoi(value); err != nil || shape.chunkSize <= 0 {
+			return nil, fmt.Errorf("invalid chunk_size format")
+		}
+	}
+	if value := shapeParam(r, "rate"); value != "" {
+		if shape.rate, err = strconv.Atoi(value); err != nil || shape.rate <= 0 {
+			return nil, fmt.Errorf("invalid rate format")
+		}
+		if shape.chunkSize == 0 {
+			shape.chunkSize = defaultChunkSize
+		}
+	}
+
+	shape.compress = shapeParam(r, "compress")
+	if shape.compress != "" && shape.compress != "gzip" && shape.compress != "deflate" {
+		return nil, fmt.Errorf("invalid compress value %q, expected gzip or deflate", shape.compress)
+	}
+
+	return shape, nil
+}
+
+// parseStatuses parses a status code or a weighted distribution such as "200:0.9,503:0.1"
+func parseStatuses(value string) ([]weightedStatus, error) {
+	var statuses []weightedStatus
+	for _, part := range strings.Split(value, ",") {
+		codeStr, weightStr, hasWeight := strings.Cut(strings.TrimSpace(part), ":")
+
+		code, err := strconv.Atoi(codeStr)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid status %q", codeStr)
+		}
+
+		weight := 1.0
+		if hasWeight {
+			if weight, err = strconv.ParseFloat(weightStr, 64); err != nil || weight < 0 {
+				return nil, fmt.Errorf("invalid weight %q for status %d", weightStr, code)
+			}
+		}
+		statuses = append(statuses, weightedStatus{code: code, weight: weight})
+	}
+	return statuses, nil
+}
+
+// pickStatus chooses a status code according to the weights
+func (s *responseShape) pickStatus() int {
+	total := 0.0
+	for _, status := range s.statuses {
+		total += status.weight
+	}
+	if total <= 0 {
+		return s.statuses[0].code
+	}
+
+	n := rand.Float64() * total
+	for _, status := range s.statuses {
+		if n < status.weight {
+			return status.code
+		}
+		n -= status.weight
+	}
+	return s.statuses[len(s.statuses)-1].code
+}
+
+// write delivers payload as JSON, or the custom body if one was requested, applying the shape
+func (s *responseShape) write(w http.ResponseWriter, r *http.Request, payload interface{}) {
+	if s.delay > 0 {
+		select {
+		case <-time.After(s.delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	var body []byte
+	contentType := "application/json"
+	if s.body != nil {
+		body = []byte(*s.body)
+		contentType = "text/plain; charset=utf-8"
+	} else {
+		var buf bytes.Buffer
+		json.NewEncoder(&buf).Encode(payload)
+		body = buf.Bytes()
+	}
+	if s.contentType != "" {
+		contentType = s.contentType
+	}
+
+	if s.compress != "" {
+		compressed, err := compress(body, s.compress)
+		if err != nil {
+			http.Error(w, "failed to compress response", http.StatusInternalServerError)
+			return
+		}
+		body = compressed
+		w.Header().Set("Content-Encoding", s.compress)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	for name, values := range s.headers {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+
+	if s.chunkSize == 0 {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(s.pickStatus())
+		w.Write(body)
+		return
+	}
+
+	// Stream the body in chunks, flushing each one and pacing them to the requested rate
+	var pause time.Duration
+	if s.rate > 0 {
+		pause = time.Duration(float64(s.chunkSize) / float64(s.rate) * float64(time.Second))
+	}
+
+	w.WriteHeader(s.pickStatus())
+	controller := http.NewResponseController(w)
+	for start := 0; start < len(body); start += s.chunkSize {
+		if start > 0 && pause > 0 {
+			select {
+			case <-time.After(pause):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		end := min(start+s.chunkSize, len(body))
+		if _, err := w.Write(body[start:end]); err != nil {
+			return
+		}
+		controller.Flush()
+	}
+}
+
+// compress encodes body with the given content encoding
+func compress(body []byte, encoding string) ([]byte, error) {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	if encoding == "gzip" {
+		writer = gzip.NewWriter(&buf)
+	} else {
+		// HTTP's deflate coding is the zlib format
+		writer = zlib.NewWriter(&buf)
+	}
+
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
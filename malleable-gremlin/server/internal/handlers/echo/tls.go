@@ -0,0 +1,82 @@
+package echo
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net/http"
+	"time"
+)
+
+// TLSInfo describes the TLS connection a request arrived on
+type TLSInfo struct {
+	Version            string            `json:"version"`
+	CipherSuite        string            `json:"cipher_suite"`
+	ServerName         string            `json:"server_name"`
+	NegotiatedProtocol string            `json:"negotiated_protocol"`
+	DidResume          bool              `json:"did_resume"`
+	ClientVerified     bool              `json:"client_verified"`
+	ClientCertificates []CertificateInfo `json:"client_certificates"`
+}
+
+// CertificateInfo describes a certificate presented by the client
+type CertificateInfo struct {
+	Subject           string    `json:"subject"`
+	Issuer            string    `json:"issuer"`
+	SerialNumber      string    `json:"serial_number"`
+	NotBefore         time.Time `json:"not_before"`
+	NotAfter          time.Time `json:"not_after"`
+	DNSNames          []string  `json:"dns_names,omitempty"`
+	EmailAddresses    []string  `json:"email_addresses,omitempty"`
+	IPAddresses       []string  `json:"ip_addresses,omitempty"`
+	URIs              []string  `json:"uris,omitempty"`
+	IsCA              bool      `json:"is_ca"`
+	SHA256Fingerprint string    `json:"sha256_fingerprint"`
+}
+
+// tlsInfo returns the TLS details of the request, or nil for plain HTTP requests
+func tlsInfo(r *http.Request) *TLSInfo {
+	state := r.TLS
+	if state == nil {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		NegotiatedProtocol: state.NegotiatedProtocol,
+		DidResume:          state.DidResume,
+		ClientVerified:     len(state.VerifiedChains) > 0,
+		ClientCertificates: make([]CertificateInfo, 0, len(state.PeerCertificates)),
+	}
+
+	for _, cert := range state.PeerCertificates {
+		info.ClientCertificates = append(info.ClientCertificates, certificateInfo(cert))
+	}
+	return info
+}
+
+func certificateInfo(cert *x509.Certificate) CertificateInfo {
+	fingerprint := sha256.Sum256(cert.Raw)
+
+	info := CertificateInfo{
+		Subject:           cert.Subject.String(),
+		Issuer:            cert.Issuer.String(),
+		SerialNumber:      cert.SerialNumber.String(),
+		NotBefore:         cert.NotBefore,
+		NotAfter:          cert.NotAfter,
+		DNSNames:          cert.DNSNames,
+		EmailAddresses:    cert.EmailAddresses,
+		IsCA:              cert.IsCA,
+		SHA256Fingerprint: hex.EncodeToString(fingerprint[:]),
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPAddresses = append(info.IPAddresses, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		info.URIs = append(info.URIs, uri.String())
+	}
+	return info
+}
@@ -0,0 +1,162 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/eyadmba/malleable-gremlin/services/health"
+	"github.com/eyadmba/malleable-gremlin/services/postgresql"
+)
+
+const postgresCheckName = "postgresql"
+
+// SetupRoutes configures routes for the Health service
+func SetupRoutes(prefix string, router *http.ServeMux, status *health.Status, pgManager *postgresql.ConnectionManager) {
+	router.HandleFunc("GET "+prefix+"/healthz", handleProbe(status, health.Healthz))
+	router.HandleFunc("GET "+prefix+"/readyz", handleProbe(status, health.Readyz))
+	router.HandleFunc("GET "+prefix+"/livez", handleProbe(status, health.Livez))
+
+	router.HandleFunc("GET "+prefix+"/health/probes", handleListProbes(status))
+	router.HandleFunc("PUT "+prefix+"/health/probes/{probe}", handleSetBehavior(status))
+	router.HandleFunc("DELETE "+prefix+"/health/probes/{probe}", handleResetBehavior(status))
+
+	router.HandleFunc("GET "+prefix+"/health/checks", handleListChecks(status))
+	router.HandleFunc("PUT "+prefix+"/health/checks/postgresql", handleSetPostgresCheck(status, pgManager))
+	router.HandleFunc("DELETE "+prefix+"/health/checks/{check}", handleRemoveCheck(status))
+}
+
+// handleProbe returns a handler answering a probe according to its configured behavior
+func handleProbe(status *health.Status, name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		result, err := status.Check(r.Context(), name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(result.StatusCode)
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
+// handleListProbes returns a handler listing the behavior of every probe
+func handleListProbes(status *health.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status.ProbeStates())
+	}
+}
+
+// handleSetBehavior returns a handler changing how a probe answers
+func handleSetBehavior(status *health.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var behavior health.Behavior
+		if err := json.NewDecoder(r.Body).Decode(&behavior); err != nil {
+			http.Error(w, "invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		state, err := status.SetBehavior(r.PathValue("probe"), behavior)
+		if err != nil {
+			writeBehaviorError(w, err)
+			return
+		}
+
+		log.Printf("Probe %s set to %+v", state.Probe, state.Behavior)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	}
+}
+
+// handleResetBehavior returns a handler making a probe healthy again
+func handleResetBehavior(status *health.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		state, err := status.ResetBehavior(r.PathValue("probe"))
+		if err != nil {
+			writeBehaviorError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(state)
+	}
+}
+
+func writeBehaviorError(w http.ResponseWriter, err error) {
+	if errors.Is(err, health.ErrUnknownProbe) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+	} else if errors.Is(err, health.ErrInvalidBehavior) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	} else {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// handleListChecks returns a handler listing the registered readiness checks
+func handleListChecks(status *health.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"checks": status.CheckNames(),
+		})
+	}
+}
+
+// handleSetPostgresCheck returns a handler making readiness depend on a PostgreSQL connection being reachable
+func handleSetPostgresCheck(status *health.Status, pgManager *postgresql.ConnectionManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ConnectionString   string `json:"connectionString"`
+			ConnectionStringID string `json:"connectionStringId"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		if req.ConnectionString == "" && req.ConnectionStringID == "" {
+			http.Error(w, postgresql.ErrNeitherInputProvided.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.ConnectionString != "" && req.ConnectionStringID != "" {
+			http.Error(w, postgresql.ErrBothInputsProvided.Error(), http.StatusBadRequest)
+			return
+		}
+
+		status.SetCheck(postgresCheckName, func(ctx context.Context) error {
+			result, err := pgManager.Connect(ctx, req.ConnectionString, req.ConnectionStringID)
+			if err != nil {
+				return err
+			}
+			if !result.Success {
+				return errors.New(result.Error)
+			}
+			return nil
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"checks": status.CheckNames(),
+		})
+	}
+}
+
+// handleRemoveCheck returns a handler unregistering a readiness check
+func handleRemoveCheck(status *health.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := status.RemoveCheck(r.PathValue("check")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"checks": status.CheckNames(),
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package httpsender
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+	"github.com/eyadmba/malleable-gremlin/services/monitor"
+)
+
+// handleCreateMonitor returns a handler registering a synthetic monitor
+func handleCreateMonitor(monitors *monitor.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var arg monitor.MonitorArgument
+		if err := json.NewDecoder(r.Body).Decode(&arg); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		info, err := monitors.Create(arg)
+		if err != nil {
+			writeMonitorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, info)
+	}
+}
+
+// handleListMonitors returns a handler listing every monitor
+func handleListMonitors(monitors *monitor.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, monitors.List())
+	}
+}
+
+// handleGetMonitor returns a handler reporting the state, availability and latency of a monitor
+func handleGetMonitor(monitors *monitor.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := monitors.Get(r.PathValue("name"))
+		if err != nil {
+			writeMonitorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	}
+}
+
+// handleMonitorHistory returns a handler listing the stored checks of a monitor, most recent first
+func handleMonitorHistory(monitors *monitor.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 0
+		if value := r.URL.Query().Get("limit"); value != "" {
+			var err error
+			if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+				http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+				return
+			}
+		}
+
+		checks, err := monitors.History(r.PathValue("name"), limit)
+		if err != nil {
+			writeMonitorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, checks)
+	}
+}
+
+// handlePauseMonitor returns a handler stopping a monitor while keeping its history
+func handlePauseMonitor(monitors *monitor.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := monitors.Pause(r.PathValue("name"))
+		if err != nil {
+			writeMonitorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	}
+}
+
+// handleResumeMonitor returns a handler starting a paused monitor again
+func handleResumeMonitor(monitors *monitor.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := monitors.Resume(r.PathValue("name"))
+		if err != nil {
+			writeMonitorError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, info)
+	}
+}
+
+// handleDeleteMonitor returns a handler stopping and removing a monitor
+func handleDeleteMonitor(monitors *monitor.Manager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := monitors.Delete(r.PathValue("name")); err != nil {
+			writeMonitorError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// writeMonitorError maps monitor errors to HTTP status codes
+func writeMonitorError(w http.ResponseWriter, err error) {
+	if egress.WriteDenied(w, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, monitor.ErrMonitorNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, monitor.ErrMonitorExists), errors.Is(err, monitor.ErrTooManyMonitors):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, monitor.ErrInvalidMonitor):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,83 @@
+package httpsender
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/eyadmba/malleable-gremlin/services/httpsender"
+)
+
+// Control headers of the proxy route; they are removed before the request is forwarded.
+// Set headers take "Name: value" and may be repeated, remove headers take a header name.
+const (
+	proxyControlPrefix         = "X-Gremlin-Proxy-"
+	headerSetRequestHeader     = proxyControlPrefix + "Set-Request-Header"
+	headerRemoveRequestHeader  = proxyControlPrefix + "Remove-Request-Header"
+	headerSetResponseHeader    = proxyControlPrefix + "Set-Response-Header"
+	headerRemoveResponseHeader = proxyControlPrefix + "Remove-Response-Header"
+)
+
+// handleProxy returns a handler forwarding any request below {prefix}/proxy/{scheme}/{host}
+// to scheme://host with its method, path, query, headers and body, streaming the response back
+func handleProxy(prefix string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheme, host := r.PathValue("scheme"), r.PathValue("host")
+		if scheme != "http" && scheme != "https" {
+			http.Error(w, fmt.Sprintf("unsupported scheme '%s', expected http or https", scheme), http.StatusBadRequest)
+			return
+		}
+
+		// The escaped path keeps encoded characters such as %2F as the client sent them. The route's
+		// segments are skipped by count rather than by text since the client may have encoded the host:
+		// the empty one before the leading slash, the prefix's, proxy, the scheme and the host.
+		skip := strings.Count(prefix, "/") + 4
+		rest := ""
+		if segments := strings.SplitN(r.URL.EscapedPath(), "/", skip+1); len(segments) > skip {
+			rest = "/" + segments[skip]
+		}
+		target, err := url.Parse(scheme + "://" + host + rest)
+		if err != nil || target.Host != host {
+			http.Error(w, fmt.Sprintf("invalid proxy target '%s'", host), http.StatusBadRequest)
+			return
+		}
+		target.RawQuery = r.URL.RawQuery
+
+		proxyTarget := &httpsender.ProxyTarget{
+			URL:                   target,
+			RemoveRequestHeaders:  r.Header.Values(headerRemoveRequestHeader),
+			RemoveResponseHeaders: r.Header.Values(headerRemoveResponseHeader),
+		}
+		if proxyTarget.SetRequestHeaders, err = parseHeaderRewrites(r.Header.Values(headerSetRequestHeader)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if proxyTarget.SetResponseHeaders, err = parseHeaderRewrites(r.Header.Values(headerSetResponseHeader)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for name := range r.Header {
+			if strings.HasPrefix(name, proxyControlPrefix) {
+				r.Header.Del(name)
+			}
+		}
+
+		httpsender.Proxy(w, r, proxyTarget)
+	}
+}
+
+// parseHeaderRewrites reads "Name: value" entries into a header
+func parseHeaderRewrites(entries []string) (http.Header, error) {
+	header := make(http.Header)
+	for _, entry := range entries {
+		name, value, ok := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header rewrite '%s', expected 'Name: value'", entry)
+		}
+		header.Add(name, strings.TrimSpace(value))
+	}
+	return header, nil
+}
@@ -2,17 +2,35 @@ package httpsender
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"net/url"
 	"strings"
 
+	"github.com/eyadmba/malleable-gremlin/services/egress"
 	"github.com/eyadmba/malleable-gremlin/services/httpsender"
+	"github.com/eyadmba/malleable-gremlin/services/monitor"
 )
 
-func SetupRoutes(prefix string, router *http.ServeMux) {
+func SetupRoutes(prefix string, router *http.ServeMux, monitors *monitor.Manager) {
 	router.HandleFunc("GET "+prefix+"/send/{forwardUrl...}", handleForwardWhenGet)
 	router.HandleFunc("POST "+prefix+"/send", handleHTTPSend)
+	router.HandleFunc("POST "+prefix+"/load", handleLoadTest)
+	router.HandleFunc("POST "+prefix+"/fanout", handleFanOut)
+	router.HandleFunc("POST "+prefix+"/workflow", handleWorkflow)
+
+	router.HandleFunc("POST "+prefix+"/monitors", handleCreateMonitor(monitors))
+	router.HandleFunc("GET "+prefix+"/monitors", handleListMonitors(monitors))
+	router.HandleFunc("GET "+prefix+"/monitors/{name}", handleGetMonitor(monitors))
+	router.HandleFunc("DELETE "+prefix+"/monitors/{name}", handleDeleteMonitor(monitors))
+	router.HandleFunc("GET "+prefix+"/monitors/{name}/history", handleMonitorHistory(monitors))
+	router.HandleFunc("POST "+prefix+"/monitors/{name}/pause", handlePauseMonitor(monitors))
+	router.HandleFunc("POST "+prefix+"/monitors/{name}/resume", handleResumeMonitor(monitors))
+
+	// Patterns without a method proxy every method, including custom verbs
+	router.HandleFunc(prefix+"/proxy/{scheme}/{host}", handleProxy(prefix))
+	router.HandleFunc(prefix+"/proxy/{scheme}/{host}/{path...}", handleProxy(prefix))
 }
 
 func handleForwardWhenGet(w http.ResponseWriter, r *http.Request) {
@@ -31,13 +49,16 @@ func handleForwardWhenGet(w http.ResponseWriter, r *http.Request) {
 		Method: "GET",
 	}
 
-	response, err := httpsender.Send(req)
-	if err != nil {
+	response, err := httpsender.SendContext(r.Context(), req)
+	if egress.WriteDenied(w, err) {
+		return
+	} else if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Server-Timing", response.Timing.ServerTiming())
 	w.WriteHeader(response.StatusCode)
 	json.NewEncoder(w).Encode(response)
 }
@@ -49,14 +70,117 @@ func handleHTTPSend(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := httpsender.Send(&req)
-	if err != nil {
+	result, err := httpsender.SendContext(r.Context(), &req)
+	if egress.WriteDenied(w, err) {
+		return
+	} else if errors.Is(err, httpsender.ErrInvalidSendArgument) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var retryErr *httpsender.RetryError
+	if errors.As(err, &retryErr) {
+		log.Printf("HTTP send failed: %v", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(retryErr)
+		return
+	} else if err != nil {
 		log.Printf("HTTP send failed: %v", err)
-		http.Error(w, "failed to send HTTP request", http.StatusInternalServerError)
+		http.Error(w, "failed to send HTTP request: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Server-Timing", result.Timing.ServerTiming())
+
+	// Assertions report the whole result with the verdict as status, so that callers need not parse it
+	if result.Verdict != "" {
+		status := http.StatusOK
+		if result.Verdict == httpsender.VerdictFail {
+			status = http.StatusExpectationFailed
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
 	w.WriteHeader(result.StatusCode)
+
+	// verbose reports the whole result, including headers and the timing breakdown
+	if r.URL.Query().Get("verbose") == "true" {
+		json.NewEncoder(w).Encode(result)
+		return
+	}
+
 	json.NewEncoder(w).Encode(result.Body)
 }
+
+// handleLoadTest sends a request repeatedly and reports throughput, status codes, errors and latencies.
+// It blocks until the test ends; disconnecting stops the test.
+func handleLoadTest(w http.ResponseWriter, r *http.Request) {
+	var arg httpsender.LoadTestArgument
+	if err := json.NewDecoder(r.Body).Decode(&arg); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	report, err := httpsender.RunLoadTest(r.Context(), &arg)
+	if err != nil {
+		if errors.Is(err, httpsender.ErrInvalidLoadTest) || errors.Is(err, httpsender.ErrInvalidSendArgument) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func handleFanOut(w http.ResponseWriter, r *http.Request) {
+	var arg httpsender.FanOutArgument
+	if err := json.NewDecoder(r.Body).Decode(&arg); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	report, err := httpsender.FanOut(r.Context(), &arg)
+	if err != nil {
+		if errors.Is(err, httpsender.ErrInvalidFanOut) || errors.Is(err, httpsender.ErrInvalidSendArgument) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+func handleWorkflow(w http.ResponseWriter, r *http.Request) {
+	var arg httpsender.WorkflowArgument
+	if err := json.NewDecoder(r.Body).Decode(&arg); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	report, err := httpsender.RunWorkflow(r.Context(), &arg)
+	if egress.WriteDenied(w, err) {
+		return
+	} else if err != nil {
+		if errors.Is(err, httpsender.ErrInvalidWorkflow) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Verdict == httpsender.VerdictFail {
+		w.WriteHeader(http.StatusExpectationFailed)
+	}
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,128 @@
+package load
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/load"
+)
+
+// handleStartCPUJob returns a handler starting CPU load in the background
+func handleStartCPUJob(jobs *load.JobManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parseCPUParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		info := jobs.Start("cpu", params, params.Timeout.Std(), func(ctx context.Context) (interface{}, error) {
+			if params.Profile != nil {
+				return load.GenerateCPUProfileLoad(ctx, *params.Profile)
+			}
+			return load.GenerateCPULoad(ctx, params.Tasks, params.Timeout.Std())
+		})
+		writeJob(w, http.StatusAccepted, info)
+	}
+}
+
+// handleStartMemoryJob returns a handler starting memory load in the background
+func handleStartMemoryJob(jobs *load.JobManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parseMemoryParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		info := jobs.Start("memory", params, max(params.GCAfter.Std(), 0), func(ctx context.Context) (interface{}, error) {
+			return load.GenerateMemoryLoad(ctx, params.Size, params.GCAfter.Std())
+		})
+		writeJob(w, http.StatusAccepted, info)
+	}
+}
+
+// handleStartIOJob returns a handler starting I/O load in the background
+func handleStartIOJob(jobs *load.JobManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parseIOParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var expected time.Duration
+		if params.Parallel > 0 {
+			rounds := (params.Tasks + params.Parallel - 1) / params.Parallel
+			expected = time.Duration(rounds) * params.Wait.Std()
+		}
+
+		info := jobs.Start("io", params, expected, func(ctx context.Context) (interface{}, error) {
+			return load.GenerateIOLoad(ctx, params.Tasks, params.Wait.Std(), params.Parallel)
+		})
+		writeJob(w, http.StatusAccepted, info)
+	}
+}
+
+// handleStartDiskJob returns a handler starting disk I/O load in the background
+func handleStartDiskJob(jobs *load.JobManager, disk load.DiskConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, err := parseDiskParams(r, disk)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		info := jobs.Start("disk", opts, opts.Duration.Std(), func(ctx context.Context) (interface{}, error) {
+			return load.GenerateDiskLoad(ctx, *opts)
+		})
+		writeJob(w, http.StatusAccepted, info)
+	}
+}
+
+// handleListJobs returns a handler listing every known job
+func handleListJobs(jobs *load.JobManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jobs.List())
+	}
+}
+
+// handleGetJob returns a handler reporting the status, progress and result of a job
+func handleGetJob(jobs *load.JobManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := jobs.Get(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJob(w, http.StatusOK, info)
+	}
+}
+
+// handleCancelJob returns a handler cancelling a running job
+func handleCancelJob(jobs *load.JobManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		info, err := jobs.Cancel(r.PathValue("id"))
+		if err != nil {
+			if errors.Is(err, load.ErrJobNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else if errors.Is(err, load.ErrJobFinished) {
+				http.Error(w, err.Error(), http.StatusConflict)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		writeJob(w, http.StatusAccepted, info)
+	}
+}
+
+func writeJob(w http.ResponseWriter, status int, info *load.JobInfo) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(info)
+}
@@ -0,0 +1,137 @@
+package load
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/load"
+)
+
+// handleMemoryState returns a handler reporting the held memory and the process RSS
+func handleMemoryState(holder *load.MemoryHolder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeMemoryState(w, holder.State())
+	}
+}
+
+// handleMemoryHold returns a handler allocating memory that is kept until released
+func handleMemoryHold(holder *load.MemoryHolder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sizeStr := r.URL.Query().Get("size")
+		if sizeStr == "" {
+			http.Error(w, "size parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		size, err := parseSize(sizeStr)
+		if err != nil {
+			http.Error(w, "invalid size format", http.StatusBadRequest)
+			return
+		}
+
+		state, err := holder.Hold(size)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeMemoryState(w, state)
+	}
+}
+
+// handleMemoryGrow returns a handler growing held memory gradually in the background
+func handleMemoryGrow(holder *load.MemoryHolder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rateStr := r.URL.Query().Get("rate")
+		intervalStr := r.URL.Query().Get("interval")
+		limitStr := r.URL.Query().Get("limit")
+
+		if rateStr == "" {
+			http.Error(w, "rate parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		rate, err := parseSize(rateStr)
+		if err != nil {
+			http.Error(w, "invalid rate format", http.StatusBadRequest)
+			return
+		}
+
+		interval := time.Second
+		if intervalStr != "" {
+			if interval, err = time.ParseDuration(intervalStr); err != nil {
+				http.Error(w, "invalid interval format", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var limit int64
+		if limitStr != "" {
+			if limit, err = parseSize(limitStr); err != nil {
+				http.Error(w, "invalid limit format", http.StatusBadRequest)
+				return
+			}
+		}
+
+		state, err := holder.Grow(rate, interval, limit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeMemoryState(w, state)
+	}
+}
+
+// handleMemoryRelease returns a handler releasing held memory at once or in steps
+func handleMemoryRelease(holder *load.MemoryHolder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		sizeStr := r.URL.Query().Get("size")
+		stepsStr := r.URL.Query().Get("steps")
+		intervalStr := r.URL.Query().Get("interval")
+
+		var size int64
+		var err error
+		if sizeStr != "" && sizeStr != "all" {
+			if size, err = parseSize(sizeStr); err != nil {
+				http.Error(w, "invalid size format", http.StatusBadRequest)
+				return
+			}
+		}
+
+		steps := 1
+		if stepsStr != "" {
+			if steps, err = strconv.Atoi(stepsStr); err != nil {
+				http.Error(w, "invalid steps format", http.StatusBadRequest)
+				return
+			}
+		}
+
+		var interval time.Duration
+		if intervalStr != "" {
+			if interval, err = time.ParseDuration(intervalStr); err != nil {
+				http.Error(w, "invalid interval format", http.StatusBadRequest)
+				return
+			}
+		}
+
+		state, err := holder.Release(size, steps, interval)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeMemoryState(w, state)
+	}
+}
+
+// handleMemoryStop returns a handler stopping the growth or release in progress
+func handleMemoryStop(holder *load.MemoryHolder) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeMemoryState(w, holder.Stop())
+	}
+}
+
+func writeMemoryState(w http.ResponseWriter, state *load.MemoryState) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
@@ -3,21 +3,39 @@ package load
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/eyadmba/malleable-gremlin/services/load"
+	"github.com/eyadmba/malleable-gremlin/services/shared"
 )
 
 // SetupRoutes configures routes for the Load service
-func SetupRoutes(prefix string, router *http.ServeMux) {
+func SetupRoutes(prefix string, router *http.ServeMux, jobs *load.JobManager, holder *load.MemoryHolder, disk load.DiskConfig) {
 	router.HandleFunc("GET "+prefix+"/cpu", handleCPULoad())
 	router.HandleFunc("GET "+prefix+"/memory", handleMemoryLoad())
 	router.HandleFunc("GET "+prefix+"/io", handleIOLoad())
+	router.HandleFunc("GET "+prefix+"/disk", handleDiskLoad(disk))
+
+	router.HandleFunc("GET "+prefix+"/memory/state", handleMemoryState(holder))
+	router.HandleFunc("POST "+prefix+"/memory/hold", handleMemoryHold(holder))
+	router.HandleFunc("POST "+prefix+"/memory/grow", handleMemoryGrow(holder))
+	router.HandleFunc("POST "+prefix+"/memory/release", handleMemoryRelease(holder))
+	router.HandleFunc("POST "+prefix+"/memory/stop", handleMemoryStop(holder))
+
+	router.HandleFunc("POST "+prefix+"/jobs/cpu", handleStartCPUJob(jobs))
+	router.HandleFunc("POST "+prefix+"/jobs/memory", handleStartMemoryJob(jobs))
+	router.HandleFunc("POST "+prefix+"/jobs/io", handleStartIOJob(jobs))
+	router.HandleFunc("POST "+prefix+"/jobs/disk", handleStartDiskJob(jobs, disk))
+	router.HandleFunc("GET "+prefix+"/jobs", handleListJobs(jobs))
+	router.HandleFunc("GET "+prefix+"/jobs/{id}", handleGetJob(jobs))
+	router.HandleFunc("DELETE "+prefix+"/jobs/{id}", handleCancelJob(jobs))
 }
 
 type LoadResponse struct {
@@ -26,36 +44,280 @@ type LoadResponse struct {
 	Error        string `json:"error"`
 }
 
-// handleCPULoad returns a handler for the CPU load endpoint
-func handleCPULoad() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		tasksStr := r.URL.Query().Get("tasks")
-		timeoutStr := r.URL.Query().Get("timeout")
+type cpuParams struct {
+	Tasks   int             `json:"tasks"`
+	Timeout shared.Duration `json:"timeout"`
+	// Profile is set when a target utilisation or shape was requested instead of full busy loops
+	Profile *load.CPUProfile `json:"profile,omitempty"`
+}
 
-		if timeoutStr == "" {
-			http.Error(w, "timeout parameter is required", http.StatusBadRequest)
-			return
+type memoryParams struct {
+	Size    int64           `json:"size"`
+	GCAfter shared.Duration `json:"gc_after"`
+}
+
+type ioParams struct {
+	Tasks    int             `json:"tasks"`
+	Wait     shared.Duration `json:"wait"`
+	Parallel int             `json:"parallel"`
+}
+
+// parseCPUParams reads and validates the CPU load parameters from the query string
+func parseCPUParams(r *http.Request) (*cpuParams, error) {
+	tasksStr := r.URL.Query().Get("tasks")
+	timeoutStr := r.URL.Query().Get("timeout")
+
+	if timeoutStr == "" {
+		return nil, fmt.Errorf("timeout parameter is required")
+	}
+
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timeout format")
+	}
+
+	var tasks int
+	if tasksStr == "cpus" {
+		tasks = runtime.NumCPU()
+	} else {
+		tasks, err = strconv.Atoi(tasksStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tasks format")
 		}
+	}
+	if tasks <= 0 {
+		return nil, fmt.Errorf("tasks must be positive")
+	}
+	if timeout <= 0 {
+		return nil, fmt.Errorf("timeout must be positive")
+	}
+
+	params := &cpuParams{Tasks: tasks, Timeout: shared.Duration(timeout)}
+	if r.URL.Query().Has("percent") || r.URL.Query().Has("shape") {
+		if params.Profile, err = parseCPUProfile(r, tasks, timeout); err != nil {
+			return nil, err
+		}
+	}
+	return params, nil
+}
+
+// parseCPUProfile reads the target utilisation and its shape over time from the query string
+func parseCPUProfile(r *http.Request, tasks int, duration time.Duration) (*load.CPUProfile, error) {
+	query := r.URL.Query()
+	profile := &load.CPUProfile{
+		Tasks:    tasks,
+		Duration: shared.Duration(duration),
+		Shape:    load.ShapeConstant,
+		Percent:  100,
+	}
+
+	if shape := query.Get("shape"); shape != "" {
+		profile.Shape = shape
+	}
 
-		timeout, err := time.ParseDuration(timeoutStr)
+	var err error
+	if value := query.Get("percent"); value != "" {
+		if profile.Percent, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("invalid percent format")
+		}
+	}
+	if value := query.Get("min_percent"); value != "" {
+		if profile.MinPercent, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("invalid min_percent format")
+		}
+	}
+	if value := query.Get("steps"); value != "" {
+		if profile.Steps, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid steps format")
+		}
+	}
+	if value := query.Get("period"); value != "" {
+		period, err := time.ParseDuration(value)
 		if err != nil {
-			http.Error(w, "invalid timeout format", http.StatusBadRequest)
-			return
+			return nil, fmt.Errorf("invalid period format")
+		}
+		profile.Period = shared.Duration(period)
+	}
+	if value := query.Get("spike_length"); value != "" {
+		length, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spike_length format")
 		}
+		profile.SpikeLength = shared.Duration(length)
+	}
+
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// parseMemoryParams reads the memory load parameters from the query string
+func parseMemoryParams(r *http.Request) (*memoryParams, error) {
+	sizeStr := r.URL.Query().Get("size")
+	gcAfterStr := r.URL.Query().Get("gc_after")
+
+	if sizeStr == "" {
+		return nil, fmt.Errorf("size parameter is required")
+	}
+
+	// Parse size (e.g., "500mb", "1.5gb")
+	size, err := parseSize(sizeStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid size format")
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("size must be positive")
+	}
 
-		var tasks int
-		if tasksStr == "cpus" {
-			tasks = runtime.NumCPU()
+	var gcAfter time.Duration
+	if gcAfterStr != "" {
+		if gcAfterStr == "-1" {
+			gcAfter = -1
 		} else {
-			tasks, err = strconv.Atoi(tasksStr)
+			gcAfter, err = time.ParseDuration(gcAfterStr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid gc_after format")
+			}
+		}
+	}
+
+	return &memoryParams{Size: size, GCAfter: shared.Duration(gcAfter)}, nil
+}
+
+// parseIOParams reads the I/O load parameters from the query string
+func parseIOParams(r *http.Request) (*ioParams, error) {
+	tasksStr := r.URL.Query().Get("tasks")
+	waitStr := r.URL.Query().Get("wait")
+	parallelStr := r.URL.Query().Get("parallel")
+
+	if tasksStr == "" || waitStr == "" || parallelStr == "" {
+		return nil, fmt.Errorf("tasks, wait, and parallel parameters are required")
+	}
+
+	tasks, err := strconv.Atoi(tasksStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tasks format")
+	}
+
+	wait, err := time.ParseDuration(waitStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wait format")
+	}
+
+	parallel, err := strconv.Atoi(parallelStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid parallel format")
+	}
+	if tasks <= 0 || wait <= 0 || parallel <= 0 {
+		return nil, fmt.Errorf("tasks, wait, and parallel must be positive")
+	}
+
+	return &ioParams{Tasks: tasks, Wait: shared.Duration(wait), Parallel: parallel}, nil
+}
+
+// parseDiskParams reads and validates the disk load parameters from the query string, using defaults for omitted ones
+func parseDiskParams(r *http.Request, disk load.DiskConfig) (*load.DiskLoadOptions, error) {
+	query := r.URL.Query()
+	opts := &load.DiskLoadOptions{
+		Dir:       disk.Directory,
+		BlockSize: 4 * 1024,
+		FileSize:  64 * 1024 * 1024,
+		Pattern:   load.PatternSequential,
+		ReadRatio: 0.5,
+		Workers:   1,
+	}
+
+	if dir := query.Get("dir"); dir != "" {
+		resolved, err := resolveDiskDir(disk.Directory, dir)
+		if err != nil {
+			return nil, err
+		}
+		opts.Dir = resolved
+	}
+	if pattern := query.Get("pattern"); pattern != "" {
+		opts.Pattern = pattern
+	}
+
+	sizes := []struct {
+		name   string
+		target *int64
+	}{
+		{"block_size", &opts.BlockSize},
+		{"file_size", &opts.FileSize},
+		{"total", &opts.TotalBytes},
+	}
+	for _, s := range sizes {
+		if value := query.Get(s.name); value != "" {
+			size, err := parseSize(value)
 			if err != nil {
-				http.Error(w, "invalid tasks format", http.StatusBadRequest)
+				return nil, fmt.Errorf("invalid %s format", s.name)
+			}
+			*s.target = size
+		}
+	}
+
+	var err error
+	if value := query.Get("read_ratio"); value != "" {
+		if opts.ReadRatio, err = strconv.ParseFloat(value, 64); err != nil {
+			return nil, fmt.Errorf("invalid read_ratio format")
+		}
+	}
+	if value := query.Get("duration"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid duration format")
+		}
+		opts.Duration = shared.Duration(duration)
+	}
+	if value := query.Get("workers"); value != "" {
+		if opts.Workers, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("invalid workers format")
+		}
+	}
+	if value := query.Get("fsync"); value != "" {
+		if opts.Fsync, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid fsync format")
+		}
+	}
+	if value := query.Get("direct"); value != "" {
+		if opts.Direct, err = strconv.ParseBool(value); err != nil {
+			return nil, fmt.Errorf("invalid direct format")
+		}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if err := disk.Check(opts); err != nil {
+		return nil, err
+	}
+	return opts, nil
+}
+
+// handleCPULoad returns a handler for the CPU load endpoint
+func handleCPULoad() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params, err := parseCPUParams(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if params.Profile != nil {
+			result, err := load.GenerateCPUProfileLoad(r.Context(), *params.Profile)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
 				return
 			}
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(result)
+			return
 		}
 
 		start := time.Now()
-		result, err := load.GenerateCPULoad(r.Context(), tasks, timeout)
+		result, err := load.GenerateCPULoad(r.Context(), params.Tasks, params.Timeout.Std())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -76,35 +338,13 @@ func handleCPULoad() http.HandlerFunc {
 // handleMemoryLoad returns a handler for the memory load endpoint
 func handleMemoryLoad() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		sizeStr := r.URL.Query().Get("size")
-		gcAfterStr := r.URL.Query().Get("gc_after")
-
-		if sizeStr == "" {
-			http.Error(w, "size parameter is required", http.StatusBadRequest)
-			return
-		}
-
-		// Parse size (e.g., "500mb", "1.5gb")
-		size, err := parseSize(sizeStr)
+		params, err := parseMemoryParams(r)
 		if err != nil {
-			http.Error(w, "invalid size format", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
-		var gcAfter time.Duration
-		if gcAfterStr != "" {
-			if gcAfterStr == "-1" {
-				gcAfter = -1
-			} else {
-				gcAfter, err = time.ParseDuration(gcAfterStr)
-				if err != nil {
-					http.Error(w, "invalid gc_after format", http.StatusBadRequest)
-					return
-				}
-			}
-		}
-
-		result, err := load.GenerateMemoryLoad(size, gcAfter)
+		result, err := load.GenerateMemoryLoad(r.Context(), params.Size, params.GCAfter.Std())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -118,35 +358,14 @@ func handleMemoryLoad() http.HandlerFunc {
 // handleIOLoad returns a handler for the I/O load endpoint
 func handleIOLoad() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		tasksStr := r.URL.Query().Get("tasks")
-		waitStr := r.URL.Query().Get("wait")
-		parallelStr := r.URL.Query().Get("parallel")
-
-		if tasksStr == "" || waitStr == "" || parallelStr == "" {
-			http.Error(w, "tasks, wait, and parallel parameters are required", http.StatusBadRequest)
-			return
-		}
-
-		tasks, err := strconv.Atoi(tasksStr)
-		if err != nil {
-			http.Error(w, "invalid tasks format", http.StatusBadRequest)
-			return
-		}
-
-		wait, err := time.ParseDuration(waitStr)
+		params, err := parseIOParams(r)
 		if err != nil {
-			http.Error(w, "invalid wait format", http.StatusBadRequest)
-			return
-		}
-
-		parallel, err := strconv.Atoi(parallelStr)
-		if err != nil {
-			http.Error(w, "invalid parallel format", http.StatusBadRequest)
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// Pass the request context to the service function
-		result, err := load.GenerateIOLoad(r.Context(), tasks, wait, parallel)
+		result, err := load.GenerateIOLoad(r.Context(), params.Tasks, params.Wait.Std(), params.Parallel)
 		if err != nil {
 			// Check if the error is due to context cancellation
 			if err == context.Canceled || err == context.DeadlineExceeded {
@@ -165,6 +384,51 @@ func handleIOLoad() http.HandlerFunc {
 	}
 }
 
+// resolveDiskDir resolves dir, relative to diskDir unless absolute, and requires it to be diskDir or
+// one of its subdirectories so that requests cannot write elsewhere. Symbolic links are followed first.
+func resolveDiskDir(diskDir, dir string) (string, error) {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(diskDir, dir)
+	}
+	root, err := filepath.EvalSymlinks(diskDir)
+	if err != nil {
+		return "", fmt.Errorf("disk directory is not available")
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("dir does not exist")
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("dir must be inside the disk directory")
+	}
+	return resolved, nil
+}
+
+// handleDiskLoad returns a handler for the disk I/O load endpoint
+func handleDiskLoad(disk load.DiskConfig) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		opts, err := parseDiskParams(r, disk)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := load.GenerateDiskLoad(r.Context(), *opts)
+		if errors.Is(err, load.ErrInvalidDiskLoadOptions) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
+
 // parseSize parses a size string (e.g., "500mb", "1.5gb") into bytes
 func parseSize(sizeStr string) (int64, error) {
 	sizeStr = strings.ToLower(strings.TrimSpace(sizeStr))
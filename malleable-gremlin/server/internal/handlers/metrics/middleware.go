@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
+)
+
+// standardMethods are reported as is; any other method is reported as OTHER so that clients
+// cannot create series without bound through the catch-all routes
+var standardMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// Instrument records the count, latency and status code of every request served by the router
+func Instrument(router *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics.HTTPRequestsInFlight.Inc()
+		defer metrics.HTTPRequestsInFlight.Dec()
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			// Handlers abort connections with http.ErrAbortHandler; record those requests before the panic
+			// reaches the server, which closes the connection
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					observeRequest(r, "aborted", start)
+				}
+				panic(err)
+			}
+		}()
+		router.ServeHTTP(recorder, r)
+
+		observeRequest(r, strconv.Itoa(recorder.status), start)
+	})
+}
+
+// observeRequest records a served request under the route pattern the router matched
+func observeRequest(r *http.Request, code string, start time.Time) {
+	// The router stores the matched pattern on the request, e.g. "GET /echo/get"
+	route := "unmatched"
+	if r.Pattern != "" {
+		_, path, found := strings.Cut(r.Pattern, " ")
+		if !found {
+			path = r.Pattern
+		}
+		route = path
+	}
+
+	method := r.Method
+	if !standardMethods[method] {
+		method = "OTHER"
+	}
+
+	metrics.HTTPRequestDuration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+	metrics.HTTPRequestsTotal.WithLabelValues(method, route, code).Inc()
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if !s.wroteHeader {
+		s.status = code
+		s.wroteHeader = true
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+// Flush keeps streaming responses working through the recorder
+func (s *statusRecorder) Flush() {
+	http.NewResponseController(s.ResponseWriter).Flush()
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,14 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
+)
+
+// SetupRoutes configures routes for the Metrics service
+func SetupRoutes(prefix string, router *http.ServeMux) {
+	router.Handle("GET "+prefix, promhttp.HandlerFor(metrics.Registry, promhttp.HandlerOpts{}))
+}
@@ -0,0 +1,164 @@
+package mock
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/mock"
+)
+
+// SetupRoutes configures routes for the Mock service. Stubs are managed below /__admin and
+// every other path below the prefix is answered by the registered stubs.
+func SetupRoutes(prefix string, router *http.ServeMux, server *mock.Server) {
+	router.HandleFunc("POST "+prefix+"/__admin/stubs", handleAddStub(server))
+	router.HandleFunc("GET "+prefix+"/__admin/stubs", handleListStubs(server))
+	router.HandleFunc("DELETE "+prefix+"/__admin/stubs", handleReset(server))
+	router.HandleFunc("GET "+prefix+"/__admin/stubs/{id}", handleGetStub(server))
+	router.HandleFunc("DELETE "+prefix+"/__admin/stubs/{id}", handleDeleteStub(server))
+	router.HandleFunc("GET "+prefix+"/__admin/journal", handleJournal(server))
+	router.HandleFunc("DELETE "+prefix+"/__admin/journal", handleClearJournal(server))
+
+	router.HandleFunc(prefix+"/{path...}", handleMock(server))
+}
+
+// handleAddStub returns a handler registering a stub
+func handleAddStub(server *mock.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var stub mock.Stub
+		if err := json.NewDecoder(r.Body).Decode(&stub); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		added, err := server.Add(&stub)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusCreated, added)
+	}
+}
+
+// handleListStubs returns a handler listing the stubs in matching order
+func handleListStubs(server *mock.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, server.List())
+	}
+}
+
+// handleReset returns a handler removing every stub and the journal
+func handleReset(server *mock.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		server.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleGetStub returns a handler reporting one stub with its hit count
+func handleGetStub(server *mock.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stub, err := server.Get(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, stub)
+	}
+}
+
+// handleDeleteStub returns a handler removing one stub
+func handleDeleteStub(server *mock.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := server.Delete(r.PathValue("id")); err != nil {
+			if errors.Is(err, mock.ErrStubNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleJournal returns a handler listing the answered requests, most recent first
+func handleJournal(server *mock.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, server.Journal())
+	}
+}
+
+// handleClearJournal returns a handler dropping the journal
+func handleClearJournal(server *mock.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		server.ClearJournal()
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// handleMock returns a handler answering requests with the first matching stub
+func handleMock(server *mock.Server) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read request body", http.StatusInternalServerError)
+			return
+		}
+
+		path := "/" + r.PathValue("path")
+		outcome := server.Match(r, path, body)
+		if outcome == nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{
+				"error":  "no stub matched",
+				"method": r.Method,
+				"path":   path,
+			})
+			return
+		}
+
+		resp := outcome.Response
+		if delay := resp.Delay.Std(); delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		if resp.Abort {
+			// Closes the connection without a response and without logging a panic
+			panic(http.ErrAbortHandler)
+		}
+
+		for k, v := range resp.Headers {
+			w.Header().Set(k, v)
+		}
+		writeBody(w, resp.Status, resp.Body)
+	}
+}
+
+// writeBody sends string bodies as is and encodes anything else as JSON
+func writeBody(w http.ResponseWriter, status int, body interface{}) {
+	switch b := body.(type) {
+	case nil:
+		w.WriteHeader(status)
+	case string:
+		w.WriteHeader(status)
+		io.WriteString(w, b)
+	default:
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(b)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
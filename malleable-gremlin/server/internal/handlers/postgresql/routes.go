@@ -6,6 +6,7 @@ import (
 	"log"
 	"net/http"
 
+	"github.com/eyadmba/malleable-gremlin/services/egress"
 	"github.com/eyadmba/malleable-gremlin/services/postgresql"
 )
 
@@ -50,6 +51,9 @@ func handlePostgresConnect(pgManager *postgresql.ConnectionManager) http.Handler
 
 		response, err := pgManager.Connect(r.Context(), req.ConnectionString, req.ConnectionStringID)
 		if err != nil {
+			if egress.WriteDenied(w, err) {
+				return
+			}
 			if errors.Is(err, postgresql.ErrBothInputsProvided) ||
 				errors.Is(err, postgresql.ErrNeitherInputProvided) ||
 				errors.Is(err, postgresql.ErrConnIDNotFound) ||
@@ -80,6 +84,9 @@ func handleExecuteQuery(pgManager *postgresql.ConnectionManager) http.HandlerFun
 
 		result, err := pgManager.ExecuteQuery(r.Context(), &req)
 		if err != nil {
+			if egress.WriteDenied(w, err) {
+				return
+			}
 			if errors.Is(err, postgresql.ErrBothInputsProvided) ||
 				errors.Is(err, postgresql.ErrNeitherInputProvided) ||
 				errors.Is(err, postgresql.ErrConnIDNotFound) ||
@@ -0,0 +1,176 @@
+package tlsconfig
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/server/internal/config"
+)
+
+// authority is a CA able to issue certificates
+type authority struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+// loadOrCreateAuthority loads the CA from the configured files, or generates a new one
+// and writes it to those files when they are configured but do not exist yet
+func loadOrCreateAuthority(cfg *config.SelfSignedConfig) (*authority, error) {
+	if cfg.CACertFile != "" {
+		pair, err := tls.LoadX509KeyPair(cfg.CACertFile, cfg.CAKeyFile)
+		if err == nil {
+			cert, err := x509.ParseCertificate(pair.Certificate[0])
+			if err != nil {
+				return nil, err
+			}
+			key, ok := pair.PrivateKey.(crypto.Signer)
+			if !ok {
+				return nil, fmt.Errorf("CA key in %s cannot sign certificates", cfg.CAKeyFile)
+			}
+			log.Printf("Loaded self-signed CA from %s", cfg.CACertFile)
+			return &authority{cert: cert, key: key}, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		// Never overwrite half of a CA that may already be trusted
+		certExists, keyExists := fileExists(cfg.CACertFile), fileExists(cfg.CAKeyFile)
+		if certExists != keyExists {
+			present, missing := cfg.CACertFile, cfg.CAKeyFile
+			if keyExists {
+				present, missing = cfg.CAKeyFile, cfg.CACertFile
+			}
+			return nil, fmt.Errorf("CA file %s exists but %s does not; restore it or remove both to generate a new CA", present, missing)
+		}
+	}
+
+	ca, err := generateAuthority(cfg.ValidFor)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CACertFile != "" {
+		if err := ca.writeFiles(cfg.CACertFile, cfg.CAKeyFile); err != nil {
+			return nil, err
+		}
+		log.Printf("Wrote generated self-signed CA to %s", cfg.CACertFile)
+	}
+	return ca, nil
+}
+
+// fileExists reports whether anything exists at path
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, fs.ErrNotExist)
+}
+
+// generateAuthority creates a new self-signed CA
+func generateAuthority(validFor time.Duration) (*authority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"Malleable Gremlin"}, CommonName: "Malleable Gremlin CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(validFor),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+
+	return &authority{cert: cert, key: key}, nil
+}
+
+// issueServerCertificate creates a leaf certificate for hosts, which may be DNS names or IP addresses
+func (a *authority) issueServerCertificate(hosts []string, validFor time.Duration) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"Malleable Gremlin"}, CommonName: hosts[0]},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(validFor),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, key.Public(), a.key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	// Serve the CA alongside the leaf so clients can inspect the whole chain
+	return tls.Certificate{
+		Certificate: [][]byte{der, a.cert.Raw},
+		PrivateKey:  key,
+	}, nil
+}
+
+// writeFiles stores the CA certificate and key as PEM files
+func (a *authority) writeFiles(certFile, keyFile string) error {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(a.key)
+	if err != nil {
+		return err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: a.cert.Raw})
+	if err := os.WriteFile(certFile, certPEM, 0o644); err != nil {
+		return err
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return os.WriteFile(keyFile, keyPEM, 0o600)
+}
+
+// randomSerial returns a random 128-bit certificate serial number
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
@@ -0,0 +1,76 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/eyadmba/malleable-gremlin/server/internal/config"
+)
+
+var clientAuthTypes = map[string]tls.ClientAuthType{
+	"none":               tls.NoClientCert,
+	"request":            tls.RequestClientCert,
+	"require":            tls.RequireAnyClientCert,
+	"verify-if-given":    tls.VerifyClientCertIfGiven,
+	"require-and-verify": tls.RequireAndVerifyClientCert,
+}
+
+var versions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Build creates the TLS configuration of the HTTPS listeners, loading the serving certificate
+// from files or issuing it from a self-signed CA
+func Build(cfg *config.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ClientAuth: clientAuthTypes[cfg.ClientAuth],
+		MinVersion: versions[cfg.MinVersion],
+	}
+
+	var ca *authority
+	if cfg.UsesSelfSigned() {
+		var err error
+		ca, err = loadOrCreateAuthority(&cfg.SelfSigned)
+		if err != nil {
+			return nil, fmt.Errorf("preparing self-signed CA: %w", err)
+		}
+
+		leaf, err := ca.issueServerCertificate(cfg.SelfSigned.Hosts, cfg.SelfSigned.ValidFor)
+		if err != nil {
+			return nil, fmt.Errorf("issuing self-signed certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{leaf}
+		log.Printf("Issued self-signed certificate for %v", cfg.SelfSigned.Hosts)
+	} else {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if tlsConfig.ClientAuth >= tls.VerifyClientCertIfGiven {
+		pool := x509.NewCertPool()
+		if cfg.ClientCAFile != "" {
+			pemData, err := os.ReadFile(cfg.ClientCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("reading client CA file: %w", err)
+			}
+			if !pool.AppendCertsFromPEM(pemData) {
+				return nil, fmt.Errorf("client CA file %s contains no certificates", cfg.ClientCAFile)
+			}
+		} else {
+			// Validation guarantees the self-signed CA exists in this case
+			pool.AddCert(ca.cert)
+		}
+		tlsConfig.ClientCAs = pool
+	}
+
+	return tlsConfig, nil
+}
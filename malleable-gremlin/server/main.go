@@ -1,56 +1,154 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
 	"flag"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 
 	"github.com/eyadmba/malleable-gremlin/server/internal"
+	"github.com/eyadmba/malleable-gremlin/server/internal/config"
 	"github.com/eyadmba/malleable-gremlin/server/internal/handlers/about"
 	"github.com/eyadmba/malleable-gremlin/server/internal/handlers/echo"
+	"github.com/eyadmba/malleable-gremlin/server/internal/handlers/health"
 	"github.com/eyadmba/malleable-gremlin/server/internal/handlers/httpsender"
 	"github.com/eyadmba/malleable-gremlin/server/internal/handlers/load"
+	"github.com/eyadmba/malleable-gremlin/server/internal/handlers/metrics"
+	"github.com/eyadmba/malleable-gremlin/server/internal/handlers/mock"
 	"github.com/eyadmba/malleable-gremlin/server/internal/handlers/postgresql"
+	"github.com/eyadmba/malleable-gremlin/server/internal/tlsconfig"
 )
 
+// args holds the parsed command line arguments
+type args struct {
+	configPath string
+	addr       string
+}
+
 // readArgs parses command line arguments
-func readArgs() string {
-	addr := flag.String("addr", ":8080", "HTTP server address")
+func readArgs() args {
+	configPath := flag.String("config", os.Getenv(config.EnvConfigFile), "path to a YAML or JSON config file")
+	addr := flag.String("addr", "", "HTTP server address, overrides server.addresses from the config")
 	flag.Parse()
-	return *addr
+	return args{configPath: *configPath, addr: *addr}
+}
+
+// loadConfig builds the configuration and exits with a report of every problem found if it is invalid
+func loadConfig(a args) *config.Config {
+	cfg, err := config.Load(a.configPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if a.addr != "" {
+		cfg.Server.Addresses = []string{a.addr}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	return cfg
 }
 
 // setupServer creates and configures the HTTP server
-func setupServer(deps *internal.Dependencies) *http.ServeMux {
+func setupServer(cfg *config.Config, deps *internal.Dependencies) http.Handler {
 	router := http.NewServeMux()
+	modules := cfg.Modules
 
-	// Setup routes for each service with its prefix
-	about.SetupRoutes("/about", router)
-	echo.SetupRoutes("/echo", router)
-	load.SetupRoutes("/load", router)
-	httpsender.SetupRoutes("/http-send", router)
-	postgresql.SetupRoutes("/postgresql", router, deps.PostgresManager)
+	// Setup routes for each enabled service with its prefix
+	if modules.Health.Enabled {
+		health.SetupRoutes(modules.Health.Prefix, router, deps.Health, deps.PostgresManager)
+	}
+	if modules.Metrics.Enabled {
+		metrics.SetupRoutes(modules.Metrics.Prefix, router)
+	}
+	if modules.About.Enabled {
+		about.SetupRoutes(modules.About.Prefix, router)
+	}
+	if modules.Echo.Enabled {
+		echo.SetupRoutes(modules.Echo.Prefix, router, deps.RequestBins)
+	}
+	if modules.Load.Enabled {
+		load.SetupRoutes(modules.Load.Prefix, router, deps.LoadJobs, deps.MemoryHolder, deps.DiskLoad)
+	}
+	if modules.HTTPSender.Enabled {
+		httpsender.SetupRoutes(modules.HTTPSender.Prefix, router, deps.Monitors)
+	}
+	if modules.PostgreSQL.Enabled {
+		postgresql.SetupRoutes(modules.PostgreSQL.Prefix, router, deps.PostgresManager)
+	}
+	if modules.Mock.Enabled {
+		mock.SetupRoutes(modules.Mock.Prefix, router, deps.MockServer)
+	}
 
+	if modules.Metrics.Enabled {
+		return metrics.Instrument(router)
+	}
 	return router
 }
 
-// runServer starts the HTTP server and handles shutdown
-func runServer(addr string, router *http.ServeMux, deps *internal.Dependencies) {
+// runServer starts the HTTP server on every configured address and handles shutdown
+func runServer(cfg *config.Config, router http.Handler, deps *internal.Dependencies) {
 	// Create a channel to listen for OS signals
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
 	// Create a channel to handle server errors
-	errChan := make(chan error, 1)
+	errChan := make(chan error, len(cfg.Server.Addresses)+len(cfg.Server.TLS.Addresses))
 
-	// Start the server in a goroutine
-	go func() {
-		log.Printf("Starting server on %s", addr)
-		errChan <- http.ListenAndServe(addr, router)
-	}()
+	// Every request context derives from handlerCtx so that requests still running
+	// when the shutdown timeout expires can be cancelled
+	handlerCtx, cancelHandlers := context.WithCancel(context.Background())
+	defer cancelHandlers()
+
+	newServer := func(addr string, tlsConfig *tls.Config) *http.Server {
+		return &http.Server{
+			Addr:        addr,
+			Handler:     router,
+			TLSConfig:   tlsConfig,
+			BaseContext: func(net.Listener) context.Context { return handlerCtx },
+		}
+	}
+
+	// Start a server per address in its own goroutine
+	servers := make([]*http.Server, 0, cap(errChan))
+	for _, addr := range cfg.Server.Addresses {
+		srv := newServer(addr, nil)
+		servers = append(servers, srv)
+
+		go func() {
+			log.Printf("Starting server on %s", addr)
+			if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errChan <- err
+			}
+		}()
+	}
+
+	if cfg.Server.TLS.Enabled() {
+		tlsConfig, err := tlsconfig.Build(&cfg.Server.TLS)
+		if err != nil {
+			log.Fatalf("TLS setup failed: %v", err)
+		}
+
+		for _, addr := range cfg.Server.TLS.Addresses {
+			srv := newServer(addr, tlsConfig)
+			servers = append(servers, srv)
+
+			go func() {
+				log.Printf("Starting TLS server on %s (client auth: %s)", addr, cfg.Server.TLS.ClientAuth)
+				// The certificates are already part of tlsConfig
+				if err := srv.ListenAndServeTLS("", ""); !errors.Is(err, http.ErrServerClosed) {
+					errChan <- err
+				}
+			}()
+		}
+	}
 
 	// Wait for either a signal or an error
 	select {
@@ -59,20 +157,28 @@ func runServer(addr string, router *http.ServeMux, deps *internal.Dependencies)
 		os.Exit(1)
 	case sig := <-sigChan:
 		log.Printf("Received signal %v, shutting down...", sig)
-		deps.Close()
 	}
+
+	// A second signal skips draining
+	go func() {
+		sig := <-sigChan
+		log.Printf("Received signal %v during shutdown, exiting immediately", sig)
+		os.Exit(1)
+	}()
+
+	shutdown(servers, cfg.Server.Shutdown, deps, cancelHandlers)
 }
 
 func main() {
-	// Parse command line arguments
-	addr := readArgs()
+	// Parse command line arguments and load the configuration
+	cfg := loadConfig(readArgs())
 
 	// Build dependencies
-	deps := internal.BuildDependencies()
+	deps := internal.BuildDependencies(cfg)
 
 	// Setup and configure the server
-	router := setupServer(deps)
+	router := setupServer(cfg, deps)
 
 	// Run the server
-	runServer(addr, router, deps)
+	runServer(cfg, router, deps)
 }
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/server/internal"
+	"github.com/eyadmba/malleable-gremlin/server/internal/config"
+)
+
+// shutdown drains the servers and then releases the dependencies. Readiness starts failing
+// first, new requests keep being accepted for the drain delay so load balancers can react, then
+// listeners are closed and in-flight requests get until the shutdown timeout to finish. Requests
+// still running after that have their contexts cancelled and their connections closed.
+func shutdown(servers []*http.Server, cfg config.ShutdownConfig, deps *internal.Dependencies, cancelHandlers context.CancelFunc) {
+	deps.Health.SetDraining()
+
+	if cfg.DrainDelay > 0 {
+		log.Printf("Readiness is failing, draining for %s before closing listeners", cfg.DrainDelay)
+		time.Sleep(cfg.DrainDelay)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	log.Printf("Closing listeners, waiting up to %s for in-flight requests", cfg.Timeout)
+	var wg sync.WaitGroup
+	for _, srv := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				log.Printf("Server on %s did not drain in time: %v", srv.Addr, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		// Cancel the remaining requests through their contexts and drop their connections
+		cancelHandlers()
+		for _, srv := range servers {
+			srv.Close()
+		}
+	}
+
+	deps.Close()
+	log.Printf("Shutdown complete")
+}
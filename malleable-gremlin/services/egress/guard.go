@@ -0,0 +1,286 @@
+// Package egress restricts the destinations outbound connections may reach. Host names and ports are
+// checked before dialing and the resolved IP address right before connecting, so DNS rebinding cannot
+// bypass the policy.
+package egress
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// metadataAddresses are cloud instance metadata endpoints outside the link-local ranges
+var metadataAddresses = []netip.Addr{
+	netip.MustParseAddr("fd00:ec2::254"),   // AWS IPv6
+	netip.MustParseAddr("100.100.100.200"), // Alibaba Cloud
+	netip.MustParseAddr("168.63.129.16"),   // Azure wire server
+}
+
+// Policy describes the allowed destinations. Deny rules win over allow rules; when any allow list of hosts
+// or CIDRs is given, a destination must match one of them.
+type Policy struct {
+	// Schemes lists the allowed URL schemes; empty allows every scheme
+	Schemes []string
+	// AllowHosts and DenyHosts are host names, optionally with a leading wildcard such as *.example.com
+	AllowHosts []string
+	DenyHosts  []string
+	AllowCIDRs []string
+	DenyCIDRs  []string
+	// Ports lists the allowed ports; empty allows every port
+	Ports []int
+	// BlockLinkLocal denies link-local addresses and cloud metadata endpoints
+	BlockLinkLocal bool
+}
+
+// DeniedError reports a destination rejected by the policy
+type DeniedError struct {
+	// Destination is the scheme, host name or address that was checked
+	Destination string `json:"destination"`
+	Reason      string `json:"reason"`
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("egress denied for %s: %s", e.Destination, e.Reason)
+}
+
+// MarshalJSON adds the error message to the structured fields
+func (e *DeniedError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{
+		"error":       e.Error(),
+		"destination": e.Destination,
+		"reason":      e.Reason,
+	})
+}
+
+// WriteDenied answers 403 Forbidden with the structured error when err comes from the policy.
+// It reports whether it wrote a response.
+func WriteDenied(w http.ResponseWriter, err error) bool {
+	var denied *DeniedError
+	if !errors.As(err, &denied) {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+	json.NewEncoder(w).Encode(denied)
+	return true
+}
+
+// Guard enforces a Policy. A nil Guard allows everything.
+type Guard struct {
+	policy     Policy
+	allowCIDRs []netip.Prefix
+	denyCIDRs  []netip.Prefix
+	dialer     net.Dialer
+}
+
+// NewGuard validates the policy
+func NewGuard(policy Policy) (*Guard, error) {
+	g := &Guard{
+		policy: policy,
+		dialer: net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+
+	var err error
+	if g.allowCIDRs, err = parsePrefixes(policy.AllowCIDRs); err != nil {
+		return nil, err
+	}
+	if g.denyCIDRs, err = parsePrefixes(policy.DenyCIDRs); err != nil {
+		return nil, err
+	}
+	for _, pattern := range append(append([]string{}, policy.AllowHosts...), policy.DenyHosts...) {
+		if err := validateHostPattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, port := range policy.Ports {
+		if port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port %d", port)
+		}
+	}
+	return g, nil
+}
+
+// RestrictsDestinations reports whether the policy has host, address or port rules, which can only be
+// enforced when dialing the destination itself
+func (g *Guard) RestrictsDestinations() bool {
+	if g == nil {
+		return false
+	}
+	p := g.policy
+	return len(p.AllowHosts) > 0 || len(p.DenyHosts) > 0 ||
+		len(p.AllowCIDRs) > 0 || len(p.DenyCIDRs) > 0 || len(p.Ports) > 0
+}
+
+// CheckScheme rejects URL schemes outside the policy
+func (g *Guard) CheckScheme(scheme string) error {
+	if g == nil || len(g.policy.Schemes) == 0 {
+		return nil
+	}
+	for _, allowed := range g.policy.Schemes {
+		if strings.EqualFold(allowed, scheme) {
+			return nil
+		}
+	}
+	return g.deny(scheme, "scheme is not allowed")
+}
+
+// DialContext connects to address ("host:port", or a socket path for unix networks) if the policy allows it
+func (g *Guard) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if g == nil {
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}
+
+	// Unix sockets, e.g. lib/pq's /var/run/postgresql/.s.PGSQL.5432, stay on this machine. Host, address
+	// and port rules cannot be checked for them, so only policies with such rules deny them.
+	if !strings.HasPrefix(network, "tcp") && !strings.HasPrefix(network, "udp") {
+		if g.RestrictsDestinations() {
+			return nil, g.deny(address, "network "+network+" is not allowed")
+		}
+		dialer := g.dialer
+		return dialer.DialContext(ctx, network, address)
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, _ := strconv.Atoi(portStr)
+	if !g.portAllowed(port) {
+		return nil, g.deny(address, "port is not allowed")
+	}
+
+	hostAllowed := false
+	if _, err := netip.ParseAddr(host); err != nil {
+		if matchesAny(g.policy.DenyHosts, host) {
+			return nil, g.deny(host, "host is denied")
+		}
+		hostAllowed = matchesAny(g.policy.AllowHosts, host)
+	}
+
+	// Control runs for every address the host resolved to, right before connecting
+	dialer := g.dialer
+	dialer.Control = func(_, resolved string, _ syscall.RawConn) error {
+		addrPort, err := netip.ParseAddrPort(resolved)
+		if err != nil {
+			return err
+		}
+		return g.checkAddr(host, addrPort.Addr().Unmap(), hostAllowed)
+	}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// Dial and DialTimeout let a Guard serve as a lib/pq Dialer
+func (g *Guard) Dial(network, address string) (net.Conn, error) {
+	return g.DialContext(context.Background(), network, address)
+}
+
+func (g *Guard) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return g.DialContext(ctx, network, address)
+}
+
+// checkAddr applies the address rules to a resolved IP address of host
+func (g *Guard) checkAddr(host string, addr netip.Addr, hostAllowed bool) error {
+	destination := addr.String()
+	if host != destination {
+		destination = fmt.Sprintf("%s (%s)", host, addr)
+	}
+
+	if g.policy.BlockLinkLocal {
+		if addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() {
+			return g.deny(destination, "link-local address")
+		}
+		for _, metadata := range metadataAddresses {
+			if addr == metadata {
+				return g.deny(destination, "cloud metadata address")
+			}
+		}
+	}
+
+	for _, prefix := range g.denyCIDRs {
+		if prefix.Contains(addr) {
+			return g.deny(destination, "address is in denied range "+prefix.String())
+		}
+	}
+
+	if len(g.policy.AllowHosts) == 0 && len(g.allowCIDRs) == 0 {
+		return nil
+	}
+	if hostAllowed {
+		return nil
+	}
+	for _, prefix := range g.allowCIDRs {
+		if prefix.Contains(addr) {
+			return nil
+		}
+	}
+	return g.deny(destination, "destination is not in the allowed hosts or ranges")
+}
+
+func (g *Guard) portAllowed(port int) bool {
+	if len(g.policy.Ports) == 0 {
+		return true
+	}
+	for _, allowed := range g.policy.Ports {
+		if allowed == port {
+			return true
+		}
+	}
+	return false
+}
+
+// deny logs and returns the error for a rejected destination
+func (g *Guard) deny(destination, reason string) error {
+	err := &DeniedError{Destination: destination, Reason: reason}
+	log.Print(err)
+	return err
+}
+
+func parsePrefixes(cidrs []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q", cidr)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// validateHostPattern accepts host names with an optional leading "*." wildcard
+func validateHostPattern(pattern string) error {
+	name := strings.TrimPrefix(pattern, "*.")
+	if name == "" || strings.ContainsAny(name, "*/: ") {
+		return fmt.Errorf("invalid host pattern %q, expected a name such as api.example.com or *.example.com", pattern)
+	}
+	return nil
+}
+
+// matchesAny reports whether host matches one of the patterns. "*.example.com" matches any subdomain
+// of example.com but not example.com itself.
+func matchesAny(patterns []string, host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(pattern)
+		if suffix, ok := strings.CutPrefix(pattern, "*"); ok {
+			if strings.HasSuffix(host, suffix) {
+				return true
+			}
+		} else if host == pattern {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,240 @@
+package egress
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestNewGuardRejectsInvalidPolicies(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy Policy
+		want   string
+	}{
+		{"bad allowed CIDR", Policy{AllowCIDRs: []string{"10.0.0.0/33"}}, `invalid CIDR "10.0.0.0/33"`},
+		{"bad denied CIDR", Policy{DenyCIDRs: []string{"10.0.0.1"}}, `invalid CIDR "10.0.0.1"`},
+		{"host with a port", Policy{AllowHosts: []string{"example.com:443"}}, "invalid host pattern"},
+		{"wildcard inside a name", Policy{DenyHosts: []string{"api.*.example.com"}}, "invalid host pattern"},
+		{"bare wildcard", Policy{AllowHosts: []string{"*."}}, "invalid host pattern"},
+		{"port zero", Policy{Ports: []int{0}}, "invalid port 0"},
+		{"port too large", Policy{Ports: []int{65536}}, "invalid port 65536"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewGuard(tt.policy)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckScheme(t *testing.T) {
+	guard := mustGuard(t, Policy{Schemes: []string{"https"}})
+
+	tests := []struct {
+		guard  *Guard
+		scheme string
+		denied bool
+	}{
+		{guard, "https", false},
+		{guard, "HTTPS", false},
+		{guard, "http", true},
+		{mustGuard(t, Policy{}), "ftp", false},
+		{nil, "ftp", false},
+	}
+	for _, tt := range tests {
+		err := tt.guard.CheckScheme(tt.scheme)
+		if denied := isDenied(err); denied != tt.denied {
+			t.Errorf("CheckScheme(%q) = %v, want denied %v", tt.scheme, err, tt.denied)
+		}
+	}
+}
+
+func TestCheckAddr(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      Policy
+		host        string
+		addr        string
+		hostAllowed bool
+		denied      bool
+	}{
+		{"empty policy allows everything", Policy{}, "10.0.0.1", "10.0.0.1", false, false},
+		{"denied range", Policy{DenyCIDRs: []string{"10.0.0.0/8"}}, "10.1.2.3", "10.1.2.3", false, true},
+		{"outside the denied range", Policy{DenyCIDRs: []string{"10.0.0.0/8"}}, "192.0.2.1", "192.0.2.1", false, false},
+		{"allowed range", Policy{AllowCIDRs: []string{"192.0.2.0/24"}}, "192.0.2.7", "192.0.2.7", false, false},
+		{"outside the allowed ranges", Policy{AllowCIDRs: []string{"192.0.2.0/24"}}, "198.51.100.1", "198.51.100.1", false, true},
+		{"allowed host", Policy{AllowHosts: []string{"api.example.com"}}, "api.example.com", "198.51.100.1", true, false},
+		{"host not allowed", Policy{AllowHosts: []string{"api.example.com"}}, "other.example.com", "198.51.100.1", false, true},
+		{
+			name:        "deny wins over an allowed host",
+			policy:      Policy{AllowHosts: []string{"api.example.com"}, DenyCIDRs: []string{"127.0.0.0/8"}},
+			host:        "api.example.com",
+			addr:        "127.0.0.1",
+			hostAllowed: true,
+			denied:      true,
+		},
+		{
+			name:   "deny wins over an allowed range",
+			policy: Policy{AllowCIDRs: []string{"10.0.0.0/8"}, DenyCIDRs: []string{"10.0.0.0/24"}},
+			host:   "10.0.0.5",
+			addr:   "10.0.0.5",
+			denied: true,
+		},
+		{"link-local IPv4", Policy{BlockLinkLocal: true}, "169.254.169.254", "169.254.169.254", false, true},
+		{"link-local IPv6", Policy{BlockLinkLocal: true}, "fe80::1", "fe80::1", false, true},
+		{"metadata address", Policy{BlockLinkLocal: true}, "100.100.100.200", "100.100.100.200", false, true},
+		{"IPv6 metadata address", Policy{BlockLinkLocal: true}, "fd00:ec2::254", "fd00:ec2::254", false, true},
+		{"link-local allowed when not blocked", Policy{}, "169.254.169.254", "169.254.169.254", false, false},
+		{"public address with link-local blocked", Policy{BlockLinkLocal: true}, "192.0.2.1", "192.0.2.1", false, false},
+		{
+			name:        "link-local is blocked for allowed hosts",
+			policy:      Policy{AllowHosts: []string{"metadata.internal"}, BlockLinkLocal: true},
+			host:        "metadata.internal",
+			addr:        "169.254.169.254",
+			hostAllowed: true,
+			denied:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := mustGuard(t, tt.policy)
+			err := guard.checkAddr(tt.host, netip.MustParseAddr(tt.addr), tt.hostAllowed)
+			if denied := isDenied(err); denied != tt.denied {
+				t.Errorf("got %v, want denied %v", err, tt.denied)
+			}
+		})
+	}
+}
+
+func TestPortAllowed(t *testing.T) {
+	tests := []struct {
+		ports []int
+		port  int
+		want  bool
+	}{
+		{nil, 8080, true},
+		{[]int{80, 443}, 443, true},
+		{[]int{80, 443}, 8443, false},
+	}
+	for _, tt := range tests {
+		guard := mustGuard(t, Policy{Ports: tt.ports})
+		if got := guard.portAllowed(tt.port); got != tt.want {
+			t.Errorf("ports %v: portAllowed(%d) = %v, want %v", tt.ports, tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestMatchesAny(t *testing.T) {
+	tests := []struct {
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{[]string{"api.example.com"}, "api.example.com", true},
+		{[]string{"api.example.com"}, "API.Example.com.", true},
+		{[]string{"api.example.com"}, "www.example.com", false},
+		{[]string{"*.example.com"}, "api.example.com", true},
+		{[]string{"*.example.com"}, "a.b.example.com", true},
+		{[]string{"*.example.com"}, "example.com", false},
+		{[]string{"*.example.com"}, "badexample.com", false},
+		{[]string{"other.org", "*.example.com"}, "api.example.com", true},
+		{nil, "example.com", false},
+	}
+	for _, tt := range tests {
+		if got := matchesAny(tt.patterns, tt.host); got != tt.want {
+			t.Errorf("matchesAny(%q, %q) = %v, want %v", tt.patterns, tt.host, got, tt.want)
+		}
+	}
+}
+
+func TestRestrictsDestinations(t *testing.T) {
+	tests := []struct {
+		name  string
+		guard *Guard
+		want  bool
+	}{
+		{"nil guard", nil, false},
+		{"scheme rules only", mustGuard(t, Policy{Schemes: []string{"https"}}), false},
+		{"link-local blocking only", mustGuard(t, Policy{BlockLinkLocal: true}), false},
+		{"denied hosts", mustGuard(t, Policy{DenyHosts: []string{"example.com"}}), true},
+		{"allowed ranges", mustGuard(t, Policy{AllowCIDRs: []string{"10.0.0.0/8"}}), true},
+		{"ports", mustGuard(t, Policy{Ports: []int{443}}), true},
+	}
+	for _, tt := range tests {
+		if got := tt.guard.RestrictsDestinations(); got != tt.want {
+			t.Errorf("%s: RestrictsDestinations() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+	address := listener.Addr().String()
+	_, port, _ := net.SplitHostPort(address)
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		network string
+		address string
+		denied  bool
+	}{
+		{"allowed loopback", Policy{AllowCIDRs: []string{"127.0.0.0/8"}}, "tcp", address, false},
+		{"denied loopback", Policy{DenyCIDRs: []string{"127.0.0.0/8"}}, "tcp", address, true},
+		{"port not allowed", Policy{Ports: []int{1}}, "tcp", address, true},
+		{"denied host name", Policy{DenyHosts: []string{"localhost"}}, "tcp", net.JoinHostPort("localhost", port), true},
+		{"resolved address is checked", Policy{DenyCIDRs: []string{"127.0.0.0/8", "::1/128"}}, "tcp", net.JoinHostPort("localhost", port), true},
+		{"unix socket with destination rules", Policy{Ports: []int{5432}}, "unix", "/var/run/postgresql/.s.PGSQL.5432", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			guard := mustGuard(t, tt.policy)
+			conn, err := guard.DialContext(context.Background(), tt.network, tt.address)
+			if conn != nil {
+				conn.Close()
+			}
+			if denied := isDenied(err); denied != tt.denied {
+				t.Errorf("got %v, want denied %v", err, tt.denied)
+			}
+			if !tt.denied && err != nil {
+				t.Errorf("got %v, want the connection to succeed", err)
+			}
+		})
+	}
+}
+
+func mustGuard(t *testing.T, policy Policy) *Guard {
+	t.Helper()
+	guard, err := NewGuard(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return guard
+}
+
+func isDenied(err error) bool {
+	var denied *DeniedError
+	return errors.As(err, &denied)
+}
@@ -0,0 +1,14 @@
+package health
+
+import "errors"
+
+var (
+	// ErrUnknownProbe indicates that the requested probe is not one of healthz, readyz or livez.
+	ErrUnknownProbe = errors.New("unknown probe")
+
+	// ErrInvalidBehavior indicates that a probe behavior has an unknown mode or inconsistent parameters.
+	ErrInvalidBehavior = errors.New("invalid probe behavior")
+
+	// ErrUnknownCheck indicates that the named readiness check is not registered.
+	ErrUnknownCheck = errors.New("readiness check not found")
+)
@@ -0,0 +1,141 @@
+package health
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// Probe behavior modes
+const (
+	ModeOK        = "ok"
+	ModeFail      = "fail"
+	ModeFlap      = "flap"
+	ModeFailAfter = "fail-after"
+)
+
+// Behavior controls how a probe answers
+type Behavior struct {
+	// Mode is one of ok, fail, flap (alternate every Period, starting healthy) or fail-after (fail once FailAfter calls were answered)
+	Mode      string          `json:"mode"`
+	Period    shared.Duration `json:"period,omitempty"`
+	FailAfter int             `json:"fail_after,omitempty"`
+	// Delay slows down every answer, in any mode
+	Delay shared.Duration `json:"delay,omitempty"`
+	// FailStatus is the HTTP status of failing answers, 503 by default
+	FailStatus int `json:"fail_status,omitempty"`
+}
+
+type ProbeState struct {
+	Probe    string    `json:"probe"`
+	Behavior Behavior  `json:"behavior"`
+	Since    time.Time `json:"since"`
+	Calls    int       `json:"calls"`
+}
+
+type probe struct {
+	behavior Behavior
+	since    time.Time
+	calls    int
+}
+
+func defaultBehavior() Behavior {
+	return Behavior{Mode: ModeOK}
+}
+
+func (b *Behavior) validate() error {
+	switch b.Mode {
+	case ModeOK, ModeFail:
+	case ModeFlap:
+		if b.Period <= 0 {
+			return fmt.Errorf("%w: mode flap requires a positive period", ErrInvalidBehavior)
+		}
+	case ModeFailAfter:
+		if b.FailAfter < 0 {
+			return fmt.Errorf("%w: fail_after must not be negative", ErrInvalidBehavior)
+		}
+	default:
+		return fmt.Errorf("%w: unknown mode '%s', expected ok, fail, flap or fail-after", ErrInvalidBehavior, b.Mode)
+	}
+
+	if b.Delay < 0 {
+		return fmt.Errorf("%w: delay must not be negative", ErrInvalidBehavior)
+	}
+	if b.FailStatus != 0 && (b.FailStatus < 100 || b.FailStatus > 599) {
+		return fmt.Errorf("%w: fail_status must be a valid HTTP status code", ErrInvalidBehavior)
+	}
+	return nil
+}
+
+func (b *Behavior) failStatus() int {
+	if b.FailStatus == 0 {
+		return 503
+	}
+	return b.FailStatus
+}
+
+// evaluate reports whether the probe should fail for the current call
+func (p *probe) evaluate(now time.Time) (bool, string) {
+	switch p.behavior.Mode {
+	case ModeFail:
+		return true, "forced to fail"
+	case ModeFlap:
+		phase := int64(now.Sub(p.since) / p.behavior.Period.Std())
+		if phase%2 == 1 {
+			return true, fmt.Sprintf("flapping every %s", p.behavior.Period.Std())
+		}
+	case ModeFailAfter:
+		if p.calls > p.behavior.FailAfter {
+			return true, fmt.Sprintf("failing after %d calls", p.behavior.FailAfter)
+		}
+	}
+	return false, ""
+}
+
+// SetBehavior replaces the behavior of a probe and resets its call counter and flap phase
+func (s *Status) SetBehavior(name string, behavior Behavior) (*ProbeState, error) {
+	if err := behavior.validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.probes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownProbe, name)
+	}
+	p.behavior = behavior
+	p.since = time.Now()
+	p.calls = 0
+	return p.state(name), nil
+}
+
+// ResetBehavior makes a probe healthy again
+func (s *Status) ResetBehavior(name string) (*ProbeState, error) {
+	return s.SetBehavior(name, defaultBehavior())
+}
+
+// ProbeStates returns the configured behavior of every probe
+func (s *Status) ProbeStates() []*ProbeState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	states := make([]*ProbeState, 0, len(s.probes))
+	for name, p := range s.probes {
+		states = append(states, p.state(name))
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].Probe < states[j].Probe })
+	return states
+}
+
+func (p *probe) state(name string) *ProbeState {
+	return &ProbeState{
+		Probe:    name,
+		Behavior: p.behavior,
+		Since:    p.since,
+		Calls:    p.calls,
+	}
+}
@@ -0,0 +1,158 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Probe names
+const (
+	Healthz = "healthz"
+	Readyz  = "readyz"
+	Livez   = "livez"
+)
+
+// CheckFunc verifies a dependency, returning an error when it is unavailable
+type CheckFunc func(ctx context.Context) error
+
+// Status tracks the state of the health probes and whether the server should keep receiving traffic
+type Status struct {
+	draining atomic.Bool
+
+	mu     sync.Mutex
+	probes map[string]*probe
+	checks map[string]CheckFunc
+}
+
+type ProbeResult struct {
+	Probe   string            `json:"probe"`
+	Healthy bool              `json:"healthy"`
+	Reason  string            `json:"reason,omitempty"`
+	Checks  map[string]string `json:"checks,omitempty"`
+	// StatusCode is the HTTP status the probe should answer with
+	StatusCode int `json:"-"`
+}
+
+func NewStatus() *Status {
+	now := time.Now()
+	return &Status{
+		probes: map[string]*probe{
+			Healthz: {behavior: defaultBehavior(), since: now},
+			Readyz:  {behavior: defaultBehavior(), since: now},
+			Livez:   {behavior: defaultBehavior(), since: now},
+		},
+		checks: make(map[string]CheckFunc),
+	}
+}
+
+// SetDraining marks the server as shutting down so readiness starts failing
+func (s *Status) SetDraining() {
+	s.draining.Store(true)
+}
+
+// Draining reports whether the server is shutting down
+func (s *Status) Draining() bool {
+	return s.draining.Load()
+}
+
+// SetCheck registers a named check that must pass for the readiness probe to succeed
+func (s *Status) SetCheck(name string, check CheckFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks[name] = check
+}
+
+// RemoveCheck unregisters a readiness check
+func (s *Status) RemoveCheck(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.checks[name]; !ok {
+		return fmt.Errorf("%w: '%s'", ErrUnknownCheck, name)
+	}
+	delete(s.checks, name)
+	return nil
+}
+
+// CheckNames lists the registered readiness checks
+func (s *Status) CheckNames() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.checks))
+	for name := range s.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check evaluates a probe according to its configured behavior. The readiness probe additionally
+// fails while draining and when any registered check fails.
+func (s *Status) Check(ctx context.Context, name string) (*ProbeResult, error) {
+	s.mu.Lock()
+	p, ok := s.probes[name]
+	if !ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("%w: '%s'", ErrUnknownProbe, name)
+	}
+	p.calls++
+	behavior := p.behavior
+	failing, reason := p.evaluate(time.Now())
+
+	var checks map[string]CheckFunc
+	if name == Readyz {
+		checks = make(map[string]CheckFunc, len(s.checks))
+		for checkName, check := range s.checks {
+			checks[checkName] = check
+		}
+	}
+	s.mu.Unlock()
+
+	// Respond slowly if requested
+	if behavior.Delay > 0 {
+		select {
+		case <-time.After(behavior.Delay.Std()):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	result := &ProbeResult{
+		Probe:   name,
+		Healthy: !failing,
+		Reason:  reason,
+	}
+
+	if name == Readyz {
+		if s.Draining() {
+			result.Healthy = false
+			result.Reason = "server is draining"
+		}
+
+		if len(checks) > 0 {
+			result.Checks = make(map[string]string, len(checks))
+			for checkName, check := range checks {
+				if err := check(ctx); err != nil {
+					result.Checks[checkName] = err.Error()
+					if result.Healthy {
+						result.Healthy = false
+						result.Reason = fmt.Sprintf("check %s failed", checkName)
+					}
+				} else {
+					result.Checks[checkName] = "ok"
+				}
+			}
+		}
+	}
+
+	result.StatusCode = 200
+	if !result.Healthy {
+		result.StatusCode = behavior.failStatus()
+	}
+	return result, nil
+}
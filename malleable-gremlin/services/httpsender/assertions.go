@@ -0,0 +1,192 @@
+package httpsender
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/internal/jsonpath"
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// Verdicts of a send with assertions
+const (
+	VerdictPass = "pass"
+	VerdictFail = "fail"
+)
+
+// Assertions are checked against the response of a send. Every field is optional.
+type Assertions struct {
+	// Status lists the accepted status codes
+	Status []int `json:"status,omitempty"`
+	// Headers maps header names to their expected value; an empty value only requires the header to be present
+	Headers map[string]string `json:"headers,omitempty"`
+	// BodyRegex must match the response body
+	BodyRegex string `json:"body_regex,omitempty"`
+	// JSONPath maps JSON paths (e.g. $.items[0].id) to the value expected in the JSON response body
+	JSONPath map[string]interface{} `json:"json_path,omitempty"`
+	// MaxLatency bounds the total time of the send
+	MaxLatency shared.Duration `json:"max_latency,omitempty"`
+	// MinCertValidity is how long the server certificate must remain valid
+	MinCertValidity shared.Duration `json:"min_cert_validity,omitempty"`
+
+	bodyRegex *regexp.Regexp
+	paths     map[string]*jsonpath.Path
+}
+
+// AssertionResult is the outcome of a single assertion
+type AssertionResult struct {
+	Assertion string      `json:"assertion"`
+	Passed    bool        `json:"passed"`
+	Expected  interface{} `json:"expected"`
+	Actual    interface{} `json:"actual"`
+}
+
+// TLSDetails describes the TLS connection of a response
+type TLSDetails struct {
+	Version            string    `json:"version"`
+	CipherSuite        string    `json:"cipher_suite"`
+	NegotiatedProtocol string    `json:"negotiated_protocol,omitempty"`
+	CertSubject        string    `json:"cert_subject"`
+	CertIssuer         string    `json:"cert_issuer"`
+	CertNotAfter       time.Time `json:"cert_not_after"`
+}
+
+// tlsDetails returns nil for plain HTTP responses
+func tlsDetails(state *tls.ConnectionState) *TLSDetails {
+	if state == nil {
+		return nil
+	}
+
+	details := &TLSDetails{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		NegotiatedProtocol: state.NegotiatedProtocol,
+	}
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		details.CertSubject = leaf.Subject.String()
+		details.CertIssuer = leaf.Issuer.String()
+		details.CertNotAfter = leaf.NotAfter
+	}
+	return details
+}
+
+// compile checks the assertions before anything is sent and returns a copy with the body regex
+// and JSON paths compiled; the assertions themselves are left untouched
+func (a *Assertions) compile() (*Assertions, error) {
+	compiled := *a
+	if a.BodyRegex != "" {
+		re, err := regexp.Compile(a.BodyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("%w: assertions.body_regex: %v", ErrInvalidSendArgument, err)
+		}
+		compiled.bodyRegex = re
+	}
+
+	compiled.paths = make(map[string]*jsonpath.Path, len(a.JSONPath))
+	for expr := range a.JSONPath {
+		p, err := jsonpath.Parse(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: assertions.json_path: %v", ErrInvalidSendArgument, err)
+		}
+		compiled.paths[expr] = p
+	}
+
+	if a.MaxLatency < 0 || a.MinCertValidity < 0 {
+		return nil, fmt.Errorf("%w: assertion durations must not be negative", ErrInvalidSendArgument)
+	}
+	return &compiled, nil
+}
+
+// evaluate checks every assertion against the result; rawBody is the body as received
+func (a *Assertions) evaluate(result *SendResult, rawBody []byte) []AssertionResult {
+	var results []AssertionResult
+	add := func(assertion string, passed bool, expected, actual interface{}) {
+		results = append(results, AssertionResult{Assertion: assertion, Passed: passed, Expected: expected, Actual: actual})
+	}
+
+	if len(a.Status) > 0 {
+		passed := false
+		for _, status := range a.Status {
+			passed = passed || status == result.StatusCode
+		}
+		add("status", passed, a.Status, result.StatusCode)
+	}
+
+	names := make([]string, 0, len(a.Headers))
+	for name := range a.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expected := a.Headers[name]
+		values := result.Headers.Values(name)
+		var actual interface{}
+		if len(values) > 0 {
+			actual = values
+		}
+
+		passed := len(values) > 0 && expected == ""
+		for _, v := range values {
+			passed = passed || v == expected
+		}
+		if expected == "" {
+			add("header "+http.CanonicalHeaderKey(name)+" present", passed, "present", actual)
+		} else {
+			add("header "+http.CanonicalHeaderKey(name), passed, expected, actual)
+		}
+	}
+
+	if a.bodyRegex != nil {
+		match := a.bodyRegex.Find(rawBody)
+		var actual interface{}
+		if match != nil {
+			actual = string(match)
+		}
+		add("body_regex", match != nil, a.BodyRegex, actual)
+	}
+
+	exprs := make([]string, 0, len(a.JSONPath))
+	for expr := range a.JSONPath {
+		exprs = append(exprs, expr)
+	}
+	sort.Strings(exprs)
+	for _, expr := range exprs {
+		var actual interface{}
+		found := false
+		if result.BodyEncoding == "json" {
+			actual, found = a.paths[expr].Lookup(result.Body)
+		}
+		add("json_path "+expr, found && reflect.DeepEqual(actual, a.JSONPath[expr]), a.JSONPath[expr], actual)
+	}
+
+	if a.MaxLatency > 0 {
+		add("max_latency", result.Timing.Total <= a.MaxLatency, a.MaxLatency, result.Timing.Total)
+	}
+
+	if a.MinCertValidity > 0 {
+		if result.TLS == nil || result.TLS.CertNotAfter.IsZero() {
+			add("min_cert_validity", false, a.MinCertValidity, nil)
+		} else {
+			remaining := shared.Duration(time.Until(result.TLS.CertNotAfter).Truncate(time.Second))
+			add("min_cert_validity", remaining >= a.MinCertValidity, a.MinCertValidity, remaining)
+		}
+	}
+
+	return results
+}
+
+// verdict is pass when every assertion passed
+func verdict(results []AssertionResult) string {
+	for _, r := range results {
+		if !r.Passed {
+			return VerdictFail
+		}
+	}
+	return VerdictPass
+}
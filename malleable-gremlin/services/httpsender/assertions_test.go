@@ -0,0 +1,145 @@
+package httpsender
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+func TestAssertionsCompileRejectsInvalidAssertions(t *testing.T) {
+	tests := []struct {
+		name       string
+		assertions Assertions
+		want       string
+	}{
+		{"bad body regex", Assertions{BodyRegex: "("}, "assertions.body_regex"},
+		{"bad JSON path", Assertions{JSONPath: map[string]interface{}{"$.items[0": 1}}, "assertions.json_path"},
+		{"negative latency", Assertions{MaxLatency: shared.Duration(-time.Second)}, "must not be negative"},
+		{"negative certificate validity", Assertions{MinCertValidity: shared.Duration(-time.Hour)}, "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.assertions.compile()
+			if !errors.Is(err, ErrInvalidSendArgument) {
+				t.Fatalf("got %v, want ErrInvalidSendArgument", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssertionsEvaluate(t *testing.T) {
+	result := &SendResult{
+		StatusCode:   http.StatusOK,
+		Headers:      http.Header{"Content-Type": {"application/json"}, "X-Trace": {"a", "b"}},
+		Body:         map[string]interface{}{"items": []interface{}{map[string]interface{}{"id": "abc", "count": float64(2)}}},
+		BodyEncoding: "json",
+		Timing:       &Timing{Total: shared.Duration(50 * time.Millisecond)},
+		TLS:          &TLSDetails{CertNotAfter: time.Now().Add(48 * time.Hour)},
+	}
+	rawBody := []byte(`{"items":[{"id":"abc","count":2}]}`)
+
+	tests := []struct {
+		name       string
+		assertions Assertions
+		want       []bool
+	}{
+		{"no assertions", Assertions{}, nil},
+		{"accepted status", Assertions{Status: []int{200, 204}}, []bool{true}},
+		{"unexpected status", Assertions{Status: []int{201}}, []bool{false}},
+		{"header value", Assertions{Headers: map[string]string{"content-type": "application/json"}}, []bool{true}},
+		{"one of several header values", Assertions{Headers: map[string]string{"X-Trace": "b"}}, []bool{true}},
+		{"wrong header value", Assertions{Headers: map[string]string{"Content-Type": "text/plain"}}, []bool{false}},
+		{"header present", Assertions{Headers: map[string]string{"X-Trace": ""}}, []bool{true}},
+		{"header missing", Assertions{Headers: map[string]string{"X-Missing": ""}}, []bool{false}},
+		{"body regex", Assertions{BodyRegex: `"id":"a\w+"`}, []bool{true}},
+		{"body regex without a match", Assertions{BodyRegex: `"id":"z"`}, []bool{false}},
+		{"JSON path string", Assertions{JSONPath: map[string]interface{}{"$.items[0].id": "abc"}}, []bool{true}},
+		{"JSON path number", Assertions{JSONPath: map[string]interface{}{"$.items[0].count": float64(2)}}, []bool{true}},
+		{"JSON path mismatch", Assertions{JSONPath: map[string]interface{}{"$.items[0].id": "xyz"}}, []bool{false}},
+		{"JSON path missing", Assertions{JSONPath: map[string]interface{}{"$.items[1].id": nil}}, []bool{false}},
+		{"latency within bound", Assertions{MaxLatency: shared.Duration(time.Second)}, []bool{true}},
+		{"latency over bound", Assertions{MaxLatency: shared.Duration(10 * time.Millisecond)}, []bool{false}},
+		{"certificate valid long enough", Assertions{MinCertValidity: shared.Duration(24 * time.Hour)}, []bool{true}},
+		{"certificate expiring too soon", Assertions{MinCertValidity: shared.Duration(72 * time.Hour)}, []bool{false}},
+		{
+			name: "results follow a stable order",
+			assertions: Assertions{
+				Status:  []int{200},
+				Headers: map[string]string{"X-Trace": "a", "Content-Type": "text/plain"},
+				JSONPath: map[string]interface{}{
+					"$.items[0].id":    "abc",
+					"$.items[0].count": float64(3),
+				},
+			},
+			want: []bool{true, false, true, false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compiled, err := tt.assertions.compile()
+			if err != nil {
+				t.Fatal(err)
+			}
+			results := compiled.evaluate(result, rawBody)
+			var got []bool
+			for _, r := range results {
+				got = append(got, r.Passed)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v (%+v)", got, tt.want, results)
+			}
+		})
+	}
+}
+
+func TestAssertionsJSONPathNeedsAJSONBody(t *testing.T) {
+	compiled, err := (&Assertions{JSONPath: map[string]interface{}{"$.id": "abc"}}).compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := &SendResult{Headers: http.Header{}, Body: `{"id":"abc"}`, BodyEncoding: "text"}
+	if results := compiled.evaluate(result, []byte(`{"id":"abc"}`)); results[0].Passed {
+		t.Errorf("got %+v, want a text body to fail JSON path assertions", results[0])
+	}
+}
+
+func TestAssertionsCertificateValidityNeedsTLS(t *testing.T) {
+	compiled, err := (&Assertions{MinCertValidity: shared.Duration(time.Hour)}).compile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := compiled.evaluate(&SendResult{Headers: http.Header{}}, nil)
+	if results[0].Passed || results[0].Actual != nil {
+		t.Errorf("got %+v, want plain HTTP to fail the certificate assertion", results[0])
+	}
+}
+
+func TestVerdict(t *testing.T) {
+	tests := []struct {
+		passed []bool
+		want   string
+	}{
+		{nil, VerdictPass},
+		{[]bool{true, true}, VerdictPass},
+		{[]bool{true, false}, VerdictFail},
+	}
+	for _, tt := range tests {
+		var results []AssertionResult
+		for _, p := range tt.passed {
+			results = append(results, AssertionResult{Passed: p})
+		}
+		if got := verdict(results); got != tt.want {
+			t.Errorf("verdict(%v) = %q, want %q", tt.passed, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package httpsender
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultMaxResponseSize bounds the response body kept when a request does not set MaxResponseSize
+const defaultMaxResponseSize = 10 << 20
+
+// maxDrainSize bounds how much of a truncated response body is read and discarded to measure it
+const maxDrainSize = 4 << 20
+
+// Body modes of SendArgument.BodyMode
+const (
+	BodyModeJSON      = "json"
+	BodyModeRaw       = "raw"
+	BodyModeBase64    = "base64"
+	BodyModeForm      = "form"
+	BodyModeMultipart = "multipart"
+)
+
+// quoteEscaper escapes quoted strings of a Content-Disposition header the way mime/multipart does
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// MultipartBody is the body of a multipart request
+type MultipartBody struct {
+	Fields map[string]string `json:"fields,omitempty"`
+	Files  []MultipartFile   `json:"files,omitempty"`
+}
+
+type MultipartFile struct {
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type,omitempty"`
+	Content     string `json:"content"`
+	// Base64 marks Content as base64-encoded binary data
+	Base64 bool `json:"base64,omitempty"`
+}
+
+// encodeBody returns the request body according to the body mode, and the content type to send
+// unless the request sets one
+func (req *SendArgument) encodeBody() ([]byte, string, error) {
+	switch req.BodyMode {
+	case "", BodyModeJSON, BodyModeRaw, BodyModeBase64, BodyModeForm, BodyModeMultipart:
+	default:
+		return nil, "", fmt.Errorf("%w: unknown body_mode '%s', expected json, raw, base64, form or multipart", ErrInvalidSendArgument, req.BodyMode)
+	}
+	if req.Body == nil {
+		return nil, "", nil
+	}
+
+	switch req.BodyMode {
+	case "", BodyModeJSON:
+		data, err := json.Marshal(req.Body)
+		return data, "", err
+
+	case BodyModeRaw:
+		s, ok := req.Body.(string)
+		if !ok {
+			return nil, "", fmt.Errorf("%w: body must be a string in raw mode", ErrInvalidSendArgument)
+		}
+		return []byte(s), "", nil
+
+	case BodyModeBase64:
+		s, ok := req.Body.(string)
+		if !ok {
+			return nil, "", fmt.Errorf("%w: body must be a string in base64 mode", ErrInvalidSendArgument)
+		}
+		data, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, "", fmt.Errorf("%w: body is not valid base64: %v", ErrInvalidSendArgument, err)
+		}
+		return data, "application/octet-stream", nil
+
+	case BodyModeForm:
+		var fields map[string]interface{}
+		if err := remarshal(req.Body, &fields); err != nil {
+			return nil, "", fmt.Errorf("%w: body must be an object of strings or string lists in form mode", ErrInvalidSendArgument)
+		}
+		form := url.Values{}
+		for k, v := range fields {
+			switch v := v.(type) {
+			case string:
+				form.Add(k, v)
+			case []interface{}:
+				for _, item := range v {
+					form.Add(k, fmt.Sprint(item))
+				}
+			default:
+				form.Add(k, fmt.Sprint(v))
+			}
+		}
+		return []byte(form.Encode()), "application/x-www-form-urlencoded", nil
+
+	case BodyModeMultipart:
+		var body MultipartBody
+		if err := remarshal(req.Body, &body); err != nil {
+			return nil, "", fmt.Errorf("%w: body must be an object with fields and files in multipart mode", ErrInvalidSendArgument)
+		}
+		return body.encode()
+	}
+	return nil, "", nil
+}
+
+func (b *MultipartBody) encode() ([]byte, string, error) {
+	var buf bytes.Buffer
+	writer := multipart.NewWriter(&buf)
+
+	// Sorted for a reproducible body
+	names := make([]string, 0, len(b.Fields))
+	for name := range b.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := writer.WriteField(name, b.Fields[name]); err != nil {
+			return nil, "", err
+		}
+	}
+
+	for i, file := range b.Files {
+		if file.Field == "" {
+			return nil, "", fmt.Errorf("%w: files[%d].field is required", ErrInvalidSendArgument, i)
+		}
+		content := []byte(file.Content)
+		if file.Base64 {
+			decoded, err := base64.StdEncoding.DecodeString(file.Content)
+			if err != nil {
+				return nil, "", fmt.Errorf("%w: files[%d].content is not valid base64: %v", ErrInvalidSendArgument, i, err)
+			}
+			content = decoded
+		}
+
+		contentType := file.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			quoteEscaper.Replace(file.Field), quoteEscaper.Replace(file.Filename)))
+		header.Set("Content-Type", contentType)
+		part, err := writer.CreatePart(header)
+		if err != nil {
+			return nil, "", err
+		}
+		part.Write(content)
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+// remarshal converts a decoded JSON value into a typed value
+func remarshal(from, to interface{}) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, to)
+}
+
+// readResponseBody reads up to limit bytes, reporting the full size and whether the body was truncated.
+// Beyond the limit at most maxDrainSize more bytes are read to measure the body; when it is longer, or
+// the rest cannot be read, the size is reported as -1 and the connection is closed with the body.
+func readResponseBody(r io.Reader, limit int64) ([]byte, int64, bool, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, 0, false, err
+	}
+	if int64(len(data)) <= limit {
+		return data, int64(len(data)), false, nil
+	}
+
+	// Drain the rest to measure it and keep the connection reusable, unless it is endless, e.g. a stream
+	rest, err := io.Copy(io.Discard, io.LimitReader(r, maxDrainSize+1))
+	if err != nil || rest > maxDrainSize {
+		return data[:limit], -1, true, nil
+	}
+	return data[:limit], int64(len(data)) + rest, true, nil
+}
+
+// decodeResponseBody returns the body as decoded JSON, text or base64 along with the encoding used
+func decodeResponseBody(data []byte, truncated bool) (interface{}, string) {
+	if !truncated {
+		var decoded interface{}
+		if err := json.Unmarshal(data, &decoded); err == nil {
+			return decoded, "json"
+		}
+	}
+	if utf8.Valid(data) {
+		return string(data), "text"
+	}
+	return base64.StdEncoding.EncodeToString(data), "base64"
+}
@@ -0,0 +1,219 @@
+package httpsender
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// defaultMaxRedirects matches the limit of http.Client's default policy
+const defaultMaxRedirects = 10
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ClientOptions control how a request is sent. The zero value behaves like http.Client{}.
+type ClientOptions struct {
+	// Timeout bounds the whole send, redirects and body included
+	Timeout shared.Duration `json:"timeout,omitempty"`
+	// Redirects is follow (the default), none or max; max follows at most MaxRedirects redirects
+	Redirects    string      `json:"redirects,omitempty"`
+	MaxRedirects int         `json:"max_redirects,omitempty"`
+	TLS          *TLSOptions `json:"tls,omitempty"`
+	// Protocol is http1 to disable HTTP/2, or http2 to fail unless HTTP/2 is negotiated over TLS
+	Protocol string `json:"protocol,omitempty"`
+	// Resolve maps "host:port" or "host" to the IP address to connect to, like curl's --resolve.
+	// The Host header and TLS server name still use the URL's host.
+	Resolve map[string]string `json:"resolve,omitempty"`
+}
+
+type TLSOptions struct {
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+	// CACertPEM replaces the system roots with the given certificates
+	CACertPEM     string `json:"ca_cert_pem,omitempty"`
+	ClientCertPEM string `json:"client_cert_pem,omitempty"`
+	ClientKeyPEM  string `json:"client_key_pem,omitempty"`
+	// ServerName overrides the SNI and the name the certificate is verified against
+	ServerName string `json:"server_name,omitempty"`
+	// MinVersion is one of 1.0, 1.1, 1.2 or 1.3
+	MinVersion string `json:"min_version,omitempty"`
+}
+
+// RedirectHop is one redirect response followed (or not) during a send
+type RedirectHop struct {
+	URL        string `json:"url"`
+	StatusCode int    `json:"status_code"`
+	Location   string `json:"location"`
+}
+
+type redirectChainKey struct{}
+
+// redirectChain collects the hops of a single send; it travels in the request context
+// so that one client can serve concurrent sends
+type redirectChain struct {
+	hops    []RedirectHop
+	limited bool
+}
+
+// newTransport builds a transport honouring the TLS, protocol and resolve options.
+// It returns nil when none is set and the shared base transport can be used.
+func (o *ClientOptions) newTransport() (*http.Transport, error) {
+	if o.TLS == nil && o.Protocol == "" && len(o.Resolve) == 0 {
+		return nil, nil
+	}
+
+	_, base := currentEgress()
+	transport := base.Clone()
+
+	if o.TLS != nil {
+		tlsConfig, err := o.TLS.build()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	switch o.Protocol {
+	case "", "http2":
+	case "http1":
+		// A non-nil empty map disables the HTTP/2 upgrade
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	default:
+		return nil, fmt.Errorf("%w: unknown protocol '%s', expected http1 or http2", ErrInvalidSendArgument, o.Protocol)
+	}
+
+	if len(o.Resolve) > 0 {
+		for host, ip := range o.Resolve {
+			if net.ParseIP(ip) == nil {
+				return nil, fmt.Errorf("%w: resolve: '%s' for '%s' is not an IP address", ErrInvalidSendArgument, ip, host)
+			}
+		}
+		// Overridden addresses are dialed through the base dialer so that the egress policy still applies
+		dial := transport.DialContext
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				return dial(ctx, network, addr)
+			}
+			ip, ok := o.Resolve[addr]
+			if !ok {
+				ip, ok = o.Resolve[host]
+			}
+			if !ok {
+				return dial(ctx, network, addr)
+			}
+			return dial(ctx, network, net.JoinHostPort(ip, port))
+		}
+	}
+
+	return transport, nil
+}
+
+func (t *TLSOptions) build() (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+		ServerName:         t.ServerName,
+	}
+
+	if t.MinVersion != "" {
+		version, ok := tlsVersions[t.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("%w: tls.min_version: unknown version '%s', expected 1.0, 1.1, 1.2 or 1.3", ErrInvalidSendArgument, t.MinVersion)
+		}
+		cfg.MinVersion = version
+	}
+
+	if t.CACertPEM != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(t.CACertPEM)) {
+			return nil, fmt.Errorf("%w: tls.ca_cert_pem: no certificate found", ErrInvalidSendArgument)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if (t.ClientCertPEM == "") != (t.ClientKeyPEM == "") {
+		return nil, fmt.Errorf("%w: tls.client_cert_pem and tls.client_key_pem must be given together", ErrInvalidSendArgument)
+	}
+	if t.ClientCertPEM != "" {
+		cert, err := tls.X509KeyPair([]byte(t.ClientCertPEM), []byte(t.ClientKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("%w: tls client certificate: %v", ErrInvalidSendArgument, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// newClient creates a client with the timeout and redirect policy; transport may be nil for the base one
+func (o *ClientOptions) newClient(transport *http.Transport) (*http.Client, error) {
+	maxRedirects := defaultMaxRedirects
+	switch o.Redirects {
+	case "", "follow":
+		if o.MaxRedirects != 0 {
+			return nil, fmt.Errorf("%w: max_redirects requires redirects 'max'", ErrInvalidSendArgument)
+		}
+	case "none":
+		maxRedirects = 0
+	case "max":
+		if o.MaxRedirects < 0 {
+			return nil, fmt.Errorf("%w: max_redirects must not be negative", ErrInvalidSendArgument)
+		}
+		maxRedirects = o.MaxRedirects
+	default:
+		return nil, fmt.Errorf("%w: unknown redirects policy '%s', expected follow, none or max", ErrInvalidSendArgument, o.Redirects)
+	}
+	if o.Timeout < 0 {
+		return nil, fmt.Errorf("%w: timeout must not be negative", ErrInvalidSendArgument)
+	}
+
+	guard, base := currentEgress()
+	if transport == nil {
+		transport = base
+	}
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   o.Timeout.Std(),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if err := guard.CheckScheme(req.URL.Scheme); err != nil {
+				return err
+			}
+			chain, _ := req.Context().Value(redirectChainKey{}).(*redirectChain)
+			if len(via) > maxRedirects {
+				// Report the redirect response instead of failing
+				if chain != nil {
+					chain.limited = maxRedirects > 0
+				}
+				return http.ErrUseLastResponse
+			}
+			if chain != nil && req.Response != nil {
+				chain.hops = append(chain.hops, RedirectHop{
+					URL:        via[len(via)-1].URL.String(),
+					StatusCode: req.Response.StatusCode,
+					Location:   req.URL.String(),
+				})
+			}
+			return nil
+		},
+	}
+	return client, nil
+}
+
+// checkProtocol fails a send that was required to use HTTP/2 but did not
+func (o *ClientOptions) checkProtocol(resp *http.Response) error {
+	if o.Protocol == "http2" && resp.ProtoMajor != 2 {
+		return fmt.Errorf("server answered with %s, HTTP/2 was required", resp.Proto)
+	}
+	return nil
+}
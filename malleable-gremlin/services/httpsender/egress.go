@@ -0,0 +1,46 @@
+package httpsender
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+)
+
+var (
+	egressMu sync.RWMutex
+	// egressGuard restricts where sends and proxied requests may connect; nil allows everything
+	egressGuard *egress.Guard
+	// baseTransport is shared by requests without transport options and cloned by those with options
+	baseTransport = newBaseTransport(nil)
+)
+
+// SetEgressGuard enforces the guard's policy on every later send, load test and proxied request
+func SetEgressGuard(guard *egress.Guard) {
+	egressMu.Lock()
+	defer egressMu.Unlock()
+
+	egressGuard = guard
+	baseTransport = newBaseTransport(guard)
+}
+
+// currentEgress returns the guard and the transport dialing through it
+func currentEgress() (*egress.Guard, *http.Transport) {
+	egressMu.RLock()
+	defer egressMu.RUnlock()
+	return egressGuard, baseTransport
+}
+
+func newBaseTransport(guard *egress.Guard) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if guard != nil {
+		transport.DialContext = guard.DialContext
+		// A proxy from the environment is dialed instead of the destination, so it is checked in its
+		// place. That is enough to keep link-local addresses out of reach, but rules on destinations
+		// would be bypassed, so proxies are not used under such rules.
+		if guard.RestrictsDestinations() {
+			transport.Proxy = nil
+		}
+	}
+	return transport
+}
@@ -0,0 +1,61 @@
+package httpsender
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+)
+
+var (
+	// ErrInvalidSendArgument indicates that the client options of a request cannot be applied
+	ErrInvalidSendArgument = errors.New("invalid send argument")
+
+	// ErrInvalidLoadTest indicates that a load test specification is incomplete or exceeds the limits
+	ErrInvalidLoadTest = errors.New("invalid load test")
+
+	// ErrInvalidFanOut indicates that a fan-out specification has no targets or too many
+	ErrInvalidFanOut = errors.New("invalid fan-out")
+
+	// ErrInvalidWorkflow indicates that a workflow has no steps, a malformed extractor or an unknown variable
+	ErrInvalidWorkflow = errors.New("invalid workflow")
+)
+
+// errorCategory groups send errors by their cause
+func errorCategory(err error) string {
+	var deniedErr *egress.DeniedError
+	var dnsErr *net.DNSError
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var recordErr tls.RecordHeaderError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &deniedErr):
+		return "egress_denied"
+	case errors.Is(err, context.Canceled):
+		return "canceled"
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.As(err, &dnsErr):
+		return "dns"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection_refused"
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return "connection_reset"
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthority), errors.As(err, &hostnameErr), errors.As(err, &recordErr):
+		return "tls"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "eof"
+	default:
+		return "other"
+	}
+}
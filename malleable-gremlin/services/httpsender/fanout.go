@@ -0,0 +1,278 @@
+package httpsender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/eyadmba/malleable-gremlin/services/internal/jsonpath"
+)
+
+// maxFanOutTargets bounds the URLs of a fan-out, which are all sent to at once
+const maxFanOutTargets = 50
+
+// Kinds of differences between fan-out targets
+const (
+	DifferenceError  = "error"
+	DifferenceStatus = "status"
+	DifferenceHeader = "header"
+	DifferenceBody   = "body"
+)
+
+// FanOutArgument sends one request to several URLs, e.g. the replicas or regions behind a service
+type FanOutArgument struct {
+	// Request is sent to every URL; its own URL is ignored
+	Request SendArgument `json:"request"`
+	URLs    []string     `json:"urls"`
+	// CompareHeaders names the response headers compared across targets
+	CompareHeaders []string `json:"compare_headers,omitempty"`
+	// IgnoreFields are left out of the body comparison: JSON paths such as $.meta.generated_at, or bare
+	// member names such as timestamp, which are ignored at any depth
+	IgnoreFields []string `json:"ignore_fields,omitempty"`
+}
+
+// FanOutTarget is the outcome of the send to one URL
+type FanOutTarget struct {
+	URL    string      `json:"url"`
+	Result *SendResult `json:"result,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// Difference is a field that is not the same for every target; Values holds it per target, in the
+// order of the URLs, with null for targets where it is absent
+type Difference struct {
+	Kind   string        `json:"kind"`
+	Field  string        `json:"field,omitempty"`
+	Values []interface{} `json:"values"`
+}
+
+// FanOutReport holds the targets side by side and what differs between them
+type FanOutReport struct {
+	Targets     []FanOutTarget `json:"targets"`
+	Identical   bool           `json:"identical"`
+	Differences []Difference   `json:"differences"`
+}
+
+// absent stands for a field missing from a target, so that it differs from an explicit null
+type absent struct{}
+
+// FanOut sends the request to every URL concurrently and compares the responses
+func FanOut(ctx context.Context, arg *FanOutArgument) (*FanOutReport, error) {
+	if len(arg.URLs) == 0 || len(arg.URLs) > maxFanOutTargets {
+		return nil, fmt.Errorf("%w: between 1 and %d urls are required", ErrInvalidFanOut, maxFanOutTargets)
+	}
+	ignored, err := parseIgnoreFields(arg.IgnoreFields)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := make([]FanOutTarget, len(arg.URLs))
+	errs := make([]error, len(arg.URLs))
+	var wg sync.WaitGroup
+	for i, u := range arg.URLs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := arg.Request
+			req.URL = u
+			targets[i] = FanOutTarget{URL: u}
+			targets[i].Result, errs[i] = SendContext(ctx, &req)
+			if errs[i] != nil {
+				targets[i].Error = errs[i].Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// The request is the same for every target, so an invalid one is rejected as a whole
+	for _, err := range errs {
+		if errors.Is(err, ErrInvalidSendArgument) {
+			return nil, err
+		}
+	}
+
+	differences := compareTargets(targets, arg.CompareHeaders, ignored)
+	return &FanOutReport{
+		Targets:     targets,
+		Identical:   len(differences) == 0,
+		Differences: differences,
+	}, nil
+}
+
+// ignoreFields tells which body fields are left out of a comparison
+type ignoreFields struct {
+	paths map[string]bool
+	names map[string]bool
+}
+
+func parseIgnoreFields(fields []string) (*ignoreFields, error) {
+	ignored := &ignoreFields{paths: map[string]bool{}, names: map[string]bool{}}
+	for _, field := range fields {
+		if !strings.ContainsAny(field, "$.[") {
+			ignored.names[field] = true
+			continue
+		}
+		p, err := jsonpath.Parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("%w: ignore_fields: %v", ErrInvalidFanOut, err)
+		}
+		ignored.paths[p.Normalized()] = true
+	}
+	return ignored, nil
+}
+
+// compareTargets reports the differences between targets. Errors are compared across every target;
+// statuses, headers and bodies only between the targets that answered, with null for the failed ones.
+func compareTargets(targets []FanOutTarget, headers []string, ignored *ignoreFields) []Difference {
+	differences := []Difference{}
+
+	errs := make([]interface{}, len(targets))
+	var answered []int
+	for i, t := range targets {
+		if t.Result == nil {
+			errs[i] = t.Error
+		} else {
+			answered = append(answered, i)
+		}
+	}
+	if !allEqual(errs) {
+		differences = append(differences, Difference{Kind: DifferenceError, Values: errs})
+	}
+
+	// compare extracts a value from every answering target and reports it when it differs
+	compare := func(kind, field string, extract func(*SendResult) interface{}) {
+		values := make([]interface{}, len(answered))
+		for j, i := range answered {
+			values[j] = extract(targets[i].Result)
+		}
+		if len(values) > 1 && !allEqual(values) {
+			differences = append(differences, Difference{Kind: kind, Field: field, Values: spread(values, answered, len(targets))})
+		}
+	}
+
+	compare(DifferenceStatus, "", func(r *SendResult) interface{} { return r.StatusCode })
+	for _, name := range headers {
+		name = http.CanonicalHeaderKey(name)
+		compare(DifferenceHeader, name, func(r *SendResult) interface{} {
+			if values := r.Headers.Values(name); len(values) > 0 {
+				return strings.Join(values, ", ")
+			}
+			return absent{}
+		})
+	}
+
+	// JSON bodies are compared field by field, any other body as a whole
+	allJSON := true
+	for _, i := range answered {
+		allJSON = allJSON && targets[i].Result.BodyEncoding == "json"
+	}
+	if !allJSON {
+		compare(DifferenceBody, "", func(r *SendResult) interface{} { return r.Body })
+	} else if len(answered) > 1 {
+		bodies := make([]interface{}, len(answered))
+		for j, i := range answered {
+			bodies[j] = targets[i].Result.Body
+		}
+		compareJSON("$", bodies, ignored, func(path string, values []interface{}) {
+			differences = append(differences, Difference{Kind: DifferenceBody, Field: path, Values: spread(values, answered, len(targets))})
+		})
+	}
+	return differences
+}
+
+// spread places the values of the answering targets at their positions among n targets, for the report
+func spread(values []interface{}, answered []int, n int) []interface{} {
+	all := make([]interface{}, n)
+	for j, i := range answered {
+		if _, ok := values[j].(absent); !ok {
+			all[i] = values[j]
+		}
+	}
+	return all
+}
+
+// compareJSON walks the documents side by side and reports every field whose values differ
+func compareJSON(path string, values []interface{}, ignored *ignoreFields, report func(string, []interface{})) {
+	if ignored.paths[path] {
+		return
+	}
+
+	if objects, ok := allOf[map[string]interface{}](values); ok {
+		keys := map[string]bool{}
+		for _, obj := range objects {
+			for key := range obj {
+				keys[key] = true
+			}
+		}
+		sorted := make([]string, 0, len(keys))
+		for key := range keys {
+			if !ignored.names[key] {
+				sorted = append(sorted, key)
+			}
+		}
+		sort.Strings(sorted)
+
+		for _, key := range sorted {
+			members := make([]interface{}, len(objects))
+			for i, obj := range objects {
+				member, ok := obj[key]
+				if !ok {
+					member = absent{}
+				}
+				members[i] = member
+			}
+			compareJSON(jsonpath.Member(path, key), members, ignored, report)
+		}
+		return
+	}
+
+	if arrays, ok := allOf[[]interface{}](values); ok {
+		length := 0
+		for _, arr := range arrays {
+			length = max(length, len(arr))
+		}
+		for i := 0; i < length; i++ {
+			elements := make([]interface{}, len(arrays))
+			for j, arr := range arrays {
+				if i < len(arr) {
+					elements[j] = arr[i]
+				} else {
+					elements[j] = absent{}
+				}
+			}
+			compareJSON(jsonpath.Element(path, i), elements, ignored, report)
+		}
+		return
+	}
+
+	if !allEqual(values) {
+		report(path, values)
+	}
+}
+
+// allOf returns the values as T when every one of them is a T
+func allOf[T any](values []interface{}) ([]T, bool) {
+	typed := make([]T, len(values))
+	for i, v := range values {
+		t, ok := v.(T)
+		if !ok {
+			return nil, false
+		}
+		typed[i] = t
+	}
+	return typed, true
+}
+
+func allEqual(values []interface{}) bool {
+	for _, v := range values[1:] {
+		if !reflect.DeepEqual(v, values[0]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,292 @@
+package httpsender
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/internal/stats"
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// Limits of a single load test
+const (
+	maxLoadTestDuration    = time.Hour
+	maxLoadTestCount       = 10_000_000
+	maxLoadTestConcurrency = 2000
+	maxLoadTestRate        = 50_000
+)
+
+// histogramBounds are the upper bounds of the latency histogram buckets
+var histogramBounds = []time.Duration{
+	time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// LoadTestArgument describes a load test. With Rate requests are started at a fixed rate (open model) and
+// Concurrency caps how many may be in flight; without Rate, Concurrency workers send back to back
+// (closed model). The test stops after Duration or Count requests, whichever comes first.
+type LoadTestArgument struct {
+	Request     SendArgument    `json:"request"`
+	Rate        float64         `json:"rate"`
+	Concurrency int             `json:"concurrency"`
+	Duration    shared.Duration `json:"duration"`
+	Count       int64           `json:"count"`
+}
+
+// HistogramBucket counts the requests whose latency is at most UpperBound and above the previous bound.
+// The last bucket has no upper bound.
+type HistogramBucket struct {
+	UpperBound *shared.Duration `json:"le"`
+	Count      int64            `json:"count"`
+}
+
+type LoadTestReport struct {
+	Requests int64           `json:"requests"`
+	Errors   int64           `json:"errors"`
+	Elapsed  shared.Duration `json:"elapsed"`
+	// Throughput is completed requests per second
+	Throughput float64 `json:"throughput"`
+	// Dropped counts requests a rate-based test skipped because Concurrency requests were already in flight.
+	// They are not part of Requests, Count or the latencies.
+	Dropped         int64                `json:"dropped"`
+	StatusCodes     map[string]int64     `json:"status_codes"`
+	ErrorCategories map[string]int64     `json:"error_categories"`
+	Latency         stats.LatencySummary `json:"latency"`
+	Histogram       []HistogramBucket    `json:"histogram"`
+}
+
+// validate checks the argument and fills in defaults
+func (a *LoadTestArgument) validate() error {
+	if a.Request.URL == "" {
+		return fmt.Errorf("%w: request.url is required", ErrInvalidLoadTest)
+	}
+	if a.Request.Method == "" {
+		a.Request.Method = http.MethodGet
+	}
+	if a.Rate < 0 || a.Rate > maxLoadTestRate {
+		return fmt.Errorf("%w: rate must be between 0 and %d", ErrInvalidLoadTest, maxLoadTestRate)
+	}
+	if a.Concurrency < 0 || a.Concurrency > maxLoadTestConcurrency {
+		return fmt.Errorf("%w: concurrency must be between 0 and %d", ErrInvalidLoadTest, maxLoadTestConcurrency)
+	}
+	if a.Rate == 0 && a.Concurrency == 0 {
+		return fmt.Errorf("%w: rate or concurrency is required", ErrInvalidLoadTest)
+	}
+	if a.Concurrency == 0 {
+		a.Concurrency = maxLoadTestConcurrency
+	}
+	if a.Duration < 0 || a.Duration.Std() > maxLoadTestDuration {
+		return fmt.Errorf("%w: duration must be between 0 and %s", ErrInvalidLoadTest, maxLoadTestDuration)
+	}
+	if a.Count < 0 || a.Count > maxLoadTestCount {
+		return fmt.Errorf("%w: count must be between 0 and %d", ErrInvalidLoadTest, maxLoadTestCount)
+	}
+	if a.Duration == 0 && a.Count == 0 {
+		return fmt.Errorf("%w: duration or count is required", ErrInvalidLoadTest)
+	}
+	if a.Duration == 0 {
+		a.Duration = shared.Duration(maxLoadTestDuration)
+	}
+	return nil
+}
+
+// loadTest aggregates the results of concurrent sends
+type loadTest struct {
+	mu              sync.Mutex
+	requests        int64
+	errors          int64
+	dropped         int64
+	statusCodes     map[string]int64
+	errorCategories map[string]int64
+	buckets         []int64
+	latency         *stats.Recorder
+}
+
+func (lt *loadTest) observe(latency time.Duration, result *SendResult, err error) {
+	lt.latency.Observe(latency)
+
+	bucket := len(histogramBounds)
+	for i, bound := range histogramBounds {
+		if latency <= bound {
+			bucket = i
+			break
+		}
+	}
+
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	lt.requests++
+	lt.buckets[bucket]++
+	if err != nil {
+		lt.errors++
+		lt.errorCategories[errorCategory(err)]++
+		return
+	}
+	lt.statusCodes[strconv.Itoa(result.StatusCode)]++
+}
+
+// RunLoadTest sends the request repeatedly as described by arg and reports the results. Requests
+// still in flight when the duration ends are awaited; cancelling ctx stops the test early.
+func RunLoadTest(ctx context.Context, arg *LoadTestArgument) (*LoadTestReport, error) {
+	if err := arg.validate(); err != nil {
+		return nil, err
+	}
+	// The request is checked and its body encoded once for every send
+	prepared, err := arg.Request.prepare()
+	if err != nil {
+		return nil, err
+	}
+
+	transport, err := arg.Request.newTransport()
+	if err != nil {
+		return nil, err
+	}
+	if transport == nil {
+		_, base := currentEgress()
+		transport = base.Clone()
+	}
+	transport.MaxIdleConnsPerHost = arg.Concurrency
+	defer transport.CloseIdleConnections()
+
+	client, err := arg.Request.newClient(transport)
+	if err != nil {
+		return nil, err
+	}
+
+	lt := &loadTest{
+		statusCodes:     make(map[string]int64),
+		errorCategories: make(map[string]int64),
+		buckets:         make([]int64, len(histogramBounds)+1),
+		latency:         stats.NewRecorder(),
+	}
+
+	// issued counts started requests so that Count is never exceeded
+	var issuedMu sync.Mutex
+	var issued int64
+	claim := func() bool {
+		issuedMu.Lock()
+		defer issuedMu.Unlock()
+		if arg.Count > 0 && issued >= arg.Count {
+			return false
+		}
+		issued++
+		return true
+	}
+
+	sendOne := func() {
+		start := time.Now()
+		result, _, err := send(ctx, client, prepared)
+		lt.observe(time.Since(start), result, err)
+	}
+
+	start := time.Now()
+	stop := time.NewTimer(arg.Duration.Std())
+	defer stop.Stop()
+
+	var wg sync.WaitGroup
+	if arg.Rate > 0 {
+		slots := make(chan struct{}, arg.Concurrency)
+		interval := time.Duration(float64(time.Second) / arg.Rate)
+		// Requests are scheduled from the start time so that slow iterations do not lower the rate
+		next := time.NewTimer(0)
+		defer next.Stop()
+
+	ticks:
+		for n := int64(1); claim(); n++ {
+			select {
+			case <-ctx.Done():
+				break ticks
+			case <-stop.C:
+				break ticks
+			case <-next.C:
+				next.Reset(time.Until(start.Add(time.Duration(n) * interval)))
+			}
+
+			select {
+			case slots <- struct{}{}:
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					defer func() { <-slots }()
+					sendOne()
+				}()
+			default:
+				// A dropped request was never sent, so it does not count toward Count
+				issuedMu.Lock()
+				issued--
+				issuedMu.Unlock()
+
+				lt.mu.Lock()
+				lt.dropped++
+				lt.mu.Unlock()
+			}
+		}
+	} else {
+		done := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+			case <-stop.C:
+			}
+			close(done)
+		}()
+
+		for i := 0; i < arg.Concurrency; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					if !claim() {
+						return
+					}
+					sendOne()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	report := &LoadTestReport{
+		Requests:        lt.requests,
+		Errors:          lt.errors,
+		Elapsed:         shared.Duration(elapsed),
+		Dropped:         lt.dropped,
+		StatusCodes:     lt.statusCodes,
+		ErrorCategories: lt.errorCategories,
+		Latency:         lt.latency.Summary(),
+	}
+	if elapsed > 0 {
+		report.Throughput = float64(lt.requests) / elapsed.Seconds()
+	}
+	for i, count := range lt.buckets {
+		bucket := HistogramBucket{Count: count}
+		if i < len(histogramBounds) {
+			bound := shared.Duration(histogramBounds[i])
+			bucket.UpperBound = &bound
+		}
+		report.Histogram = append(report.Histogram, bucket)
+	}
+	return report, nil
+}
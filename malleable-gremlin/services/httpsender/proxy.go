@@ -0,0 +1,85 @@
+package httpsender
+
+import (
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
+)
+
+// ProxyTarget describes where a proxied request goes and how its headers are rewritten
+type ProxyTarget struct {
+	// URL is the upstream scheme, host, path and query
+	URL                   *url.URL
+	SetRequestHeaders     http.Header
+	RemoveRequestHeaders  []string
+	SetResponseHeaders    http.Header
+	RemoveResponseHeaders []string
+}
+
+// Proxy forwards r to the target and streams the response back unchanged, apart from the header
+// rewrites. X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto are added to the request.
+func Proxy(w http.ResponseWriter, r *http.Request, target *ProxyTarget) {
+	guard, transport := currentEgress()
+	if err := guard.CheckScheme(target.URL.Scheme); err != nil {
+		writeProxyError(w, err)
+		return
+	}
+
+	start := time.Now()
+	metrics.HTTPSenderRequestsInFlight.Inc()
+	defer func() {
+		metrics.HTTPSenderRequestsInFlight.Dec()
+		metrics.HTTPSenderRequestDuration.WithLabelValues(r.Method).Observe(time.Since(start).Seconds())
+	}()
+
+	proxy := &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			upstream := *target.URL
+			pr.Out.URL = &upstream
+			// An empty Host makes the request use the upstream host
+			pr.Out.Host = ""
+			pr.SetXForwarded()
+
+			for _, name := range target.RemoveRequestHeaders {
+				pr.Out.Header.Del(name)
+			}
+			for name, values := range target.SetRequestHeaders {
+				pr.Out.Header[http.CanonicalHeaderKey(name)] = values
+			}
+		},
+		// Flush after every write so that SSE and other streamed responses are not buffered
+		FlushInterval: -1,
+		ModifyResponse: func(resp *http.Response) error {
+			metrics.HTTPSenderRequestsTotal.WithLabelValues(r.Method, strconv.Itoa(resp.StatusCode)).Inc()
+
+			for _, name := range target.RemoveResponseHeaders {
+				resp.Header.Del(name)
+			}
+			for name, values := range target.SetResponseHeaders {
+				resp.Header[http.CanonicalHeaderKey(name)] = values
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			metrics.HTTPSenderRequestsTotal.WithLabelValues(r.Method, "error").Inc()
+			log.Printf("Proxy to %s failed: %v", target.URL.Redacted(), err)
+			writeProxyError(w, err)
+		},
+	}
+	proxy.ServeHTTP(w, r)
+}
+
+// writeProxyError answers 403 for destinations denied by the egress policy and 502 for other failures
+func writeProxyError(w http.ResponseWriter, err error) {
+	if egress.WriteDenied(w, err) {
+		return
+	}
+	http.Error(w, "proxy error: "+err.Error(), http.StatusBadGateway)
+}
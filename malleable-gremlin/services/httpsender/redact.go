@@ -0,0 +1,44 @@
+package httpsender
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// redactedValue replaces credentials in a redacted request
+const redactedValue = "[redacted]"
+
+// sensitiveHeaders carry credentials and are redacted when a request is reported back
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+// Redacted returns a copy of the request safe to report to other clients: the values of credential
+// headers and the TLS client key are replaced by "[redacted]" and the URL password by "xxxxx"
+func (req SendArgument) Redacted() SendArgument {
+	if u, err := url.Parse(req.URL); err == nil && u.User != nil {
+		req.URL = u.Redacted()
+	}
+
+	if len(req.Headers) > 0 {
+		headers := make(map[string]string, len(req.Headers))
+		for k, v := range req.Headers {
+			if sensitiveHeaders[http.CanonicalHeaderKey(k)] {
+				v = redactedValue
+			}
+			headers[k] = v
+		}
+		req.Headers = headers
+	}
+
+	if req.TLS != nil && req.TLS.ClientKeyPEM != "" {
+		tls := *req.TLS
+		tls.ClientKeyPEM = redactedValue
+		req.TLS = &tls
+	}
+	return req
+}
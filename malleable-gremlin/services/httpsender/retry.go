@@ -0,0 +1,238 @@
+package httpsender
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// Limits and defaults of a retry policy
+const (
+	maxRetryAttempts     = 20
+	defaultRetryAttempts = 3
+	defaultInitialDelay  = 100 * time.Millisecond
+	defaultMaxDelay      = 10 * time.Second
+	defaultMultiplier    = 2
+)
+
+// Backoff strategies of a retry policy
+const (
+	BackoffConstant          = "constant"
+	BackoffExponential       = "exponential"
+	BackoffExponentialJitter = "exponential-jitter"
+)
+
+var (
+	defaultRetryStatuses = []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout}
+	defaultRetryErrors   = []string{"timeout", "connection_refused", "connection_reset", "eof"}
+	// retryableErrors are the error categories a policy may retry; denied and cancelled sends never are
+	retryableErrors = map[string]bool{
+		"timeout": true, "dns": true, "connection_refused": true, "connection_reset": true, "tls": true, "eof": true, "other": true,
+	}
+)
+
+// RetryPolicy repeats attempts that fail with a retryable status or error
+type RetryPolicy struct {
+	// MaxAttempts counts the first attempt, 3 by default
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// Statuses are retried; 429, 502, 503 and 504 by default
+	Statuses []int `json:"statuses,omitempty"`
+	// Errors are the error categories retried: timeout, dns, connection_refused, connection_reset, tls, eof
+	// or other; timeout, connection_refused, connection_reset and eof by default
+	Errors []string `json:"errors,omitempty"`
+	// Backoff is constant, exponential (the default) or exponential-jitter
+	Backoff      string          `json:"backoff,omitempty"`
+	InitialDelay shared.Duration `json:"initial_delay,omitempty"`
+	MaxDelay     shared.Duration `json:"max_delay,omitempty"`
+	Multiplier   float64         `json:"multiplier,omitempty"`
+	// IgnoreRetryAfter uses the backoff even when the response carries a Retry-After header
+	IgnoreRetryAfter bool `json:"ignore_retry_after,omitempty"`
+}
+
+// Attempt describes one attempt of a send with a retry policy
+type Attempt struct {
+	Attempt       int             `json:"attempt"`
+	StatusCode    int             `json:"status_code,omitempty"`
+	Error         string          `json:"error,omitempty"`
+	ErrorCategory string          `json:"error_category,omitempty"`
+	Duration      shared.Duration `json:"duration"`
+	Timing        *Timing         `json:"timing,omitempty"`
+	// Delay is the wait before the next attempt; RetryAfter tells it came from the Retry-After header
+	Delay      shared.Duration `json:"delay,omitempty"`
+	RetryAfter bool            `json:"retry_after,omitempty"`
+}
+
+// RetryError reports a send whose last attempt failed with an error
+type RetryError struct {
+	Attempts []Attempt
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("all %d attempts failed, last error: %v", len(e.Attempts), e.Err)
+}
+
+func (e *RetryError) Unwrap() error {
+	return e.Err
+}
+
+// MarshalJSON reports the error message with the attempt history
+func (e *RetryError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"error":    e.Error(),
+		"attempts": e.Attempts,
+	})
+}
+
+// withDefaults checks the policy and returns a copy with the defaults filled in
+func (p *RetryPolicy) withDefaults() (*RetryPolicy, error) {
+	policy := *p
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = defaultRetryAttempts
+	}
+	if policy.MaxAttempts < 1 || policy.MaxAttempts > maxRetryAttempts {
+		return nil, fmt.Errorf("%w: retry.max_attempts must be between 1 and %d", ErrInvalidSendArgument, maxRetryAttempts)
+	}
+	if len(policy.Statuses) == 0 {
+		policy.Statuses = defaultRetryStatuses
+	}
+	if len(policy.Errors) == 0 {
+		policy.Errors = defaultRetryErrors
+	}
+	for _, category := range policy.Errors {
+		if !retryableErrors[category] {
+			return nil, fmt.Errorf("%w: retry.errors: unknown error category '%s'", ErrInvalidSendArgument, category)
+		}
+	}
+
+	switch policy.Backoff {
+	case "":
+		policy.Backoff = BackoffExponential
+	case BackoffConstant, BackoffExponential, BackoffExponentialJitter:
+	default:
+		return nil, fmt.Errorf("%w: retry.backoff: unknown strategy '%s', expected constant, exponential or exponential-jitter", ErrInvalidSendArgument, policy.Backoff)
+	}
+	if policy.InitialDelay < 0 || policy.MaxDelay < 0 || policy.Multiplier < 0 {
+		return nil, fmt.Errorf("%w: retry delays and multiplier must not be negative", ErrInvalidSendArgument)
+	}
+	if policy.InitialDelay == 0 {
+		policy.InitialDelay = shared.Duration(defaultInitialDelay)
+	}
+	if policy.MaxDelay == 0 {
+		policy.MaxDelay = shared.Duration(defaultMaxDelay)
+	}
+	if policy.Multiplier == 0 {
+		policy.Multiplier = defaultMultiplier
+	}
+	return &policy, nil
+}
+
+// run calls attempt until it succeeds, fails with something not retryable or the attempts are used up.
+// A nil policy makes a single attempt and records no history.
+func (p *RetryPolicy) run(ctx context.Context, attempt func() (*SendResult, []byte, error)) (*SendResult, []byte, error) {
+	if p == nil {
+		return attempt()
+	}
+
+	var history []Attempt
+	for n := 1; ; n++ {
+		start := time.Now()
+		result, body, err := attempt()
+
+		record := Attempt{Attempt: n, Duration: shared.Duration(time.Since(start))}
+		retryable := false
+		var retryAfter time.Duration
+		if err != nil {
+			record.Error = err.Error()
+			record.ErrorCategory = errorCategory(err)
+			retryable = p.retriesError(record.ErrorCategory)
+		} else {
+			record.StatusCode = result.StatusCode
+			record.Timing = result.Timing
+			retryable = p.retriesStatus(result.StatusCode)
+			retryAfter = parseRetryAfter(result.Headers.Get("Retry-After"))
+		}
+
+		if !retryable || n == p.MaxAttempts {
+			history = append(history, record)
+			if err != nil {
+				return nil, nil, &RetryError{Attempts: history, Err: err}
+			}
+			result.Attempts = history
+			return result, body, nil
+		}
+
+		delay := p.backoff(n)
+		if retryAfter > 0 && !p.IgnoreRetryAfter {
+			delay = min(retryAfter, p.MaxDelay.Std())
+			record.RetryAfter = true
+		}
+		record.Delay = shared.Duration(delay)
+		history = append(history, record)
+
+		select {
+		case <-ctx.Done():
+			return nil, nil, &RetryError{Attempts: history, Err: ctx.Err()}
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff returns the wait after the given attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialDelay.Std()
+	if p.Backoff != BackoffConstant {
+		delay = time.Duration(float64(delay) * math.Pow(p.Multiplier, float64(attempt-1)))
+	}
+	// Large exponents overflow into negative durations
+	if delay > p.MaxDelay.Std() || delay < 0 {
+		delay = p.MaxDelay.Std()
+	}
+	if p.Backoff == BackoffExponentialJitter && delay > 0 {
+		// Full jitter spreads retries of concurrent clients over the whole interval
+		delay = rand.N(delay + 1)
+	}
+	return delay
+}
+
+func (p *RetryPolicy) retriesStatus(status int) bool {
+	for _, s := range p.Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) retriesError(category string) bool {
+	if !retryableErrors[category] {
+		return false
+	}
+	for _, c := range p.Errors {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRetryAfter reads delay-seconds or an HTTP date; it returns zero when the header is absent or invalid
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return 0
+}
@@ -0,0 +1,261 @@
+package httpsender
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+func TestRetryPolicyDefaults(t *testing.T) {
+	policy, err := (&RetryPolicy{}).withDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &RetryPolicy{
+		MaxAttempts:  defaultRetryAttempts,
+		Statuses:     defaultRetryStatuses,
+		Errors:       defaultRetryErrors,
+		Backoff:      BackoffExponential,
+		InitialDelay: shared.Duration(defaultInitialDelay),
+		MaxDelay:     shared.Duration(defaultMaxDelay),
+		Multiplier:   defaultMultiplier,
+	}
+	if !reflect.DeepEqual(policy, want) {
+		t.Errorf("got %+v, want %+v", policy, want)
+	}
+}
+
+func TestRetryPolicyValidation(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RetryPolicy
+		want   string
+	}{
+		{"negative attempts", RetryPolicy{MaxAttempts: -1}, "retry.max_attempts"},
+		{"too many attempts", RetryPolicy{MaxAttempts: maxRetryAttempts + 1}, "retry.max_attempts"},
+		{"unknown error category", RetryPolicy{Errors: []string{"timeout", "flaky"}}, "unknown error category 'flaky'"},
+		{"denied sends are never retried", RetryPolicy{Errors: []string{"egress_denied"}}, "unknown error category"},
+		{"unknown backoff", RetryPolicy{Backoff: "linear"}, "unknown strategy 'linear'"},
+		{"negative delay", RetryPolicy{InitialDelay: shared.Duration(-time.Second)}, "must not be negative"},
+		{"negative multiplier", RetryPolicy{Multiplier: -2}, "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.policy.withDefaults()
+			if !errors.Is(err, ErrInvalidSendArgument) {
+				t.Fatalf("got %v, want ErrInvalidSendArgument", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff string
+		attempt int
+		want    time.Duration
+	}{
+		{"constant first", BackoffConstant, 1, 100 * time.Millisecond},
+		{"constant later", BackoffConstant, 5, 100 * time.Millisecond},
+		{"exponential first", BackoffExponential, 1, 100 * time.Millisecond},
+		{"exponential third", BackoffExponential, 3, 400 * time.Millisecond},
+		{"exponential capped", BackoffExponential, 10, time.Second},
+		{"exponential overflow is capped", BackoffExponential, 2000, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := mustRetryPolicy(t, RetryPolicy{
+				Backoff:      tt.backoff,
+				InitialDelay: shared.Duration(100 * time.Millisecond),
+				MaxDelay:     shared.Duration(time.Second),
+			})
+			if got := policy.backoff(tt.attempt); got != tt.want {
+				t.Errorf("backoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyJitterStaysInRange(t *testing.T) {
+	policy := mustRetryPolicy(t, RetryPolicy{
+		Backoff:      BackoffExponentialJitter,
+		InitialDelay: shared.Duration(100 * time.Millisecond),
+		MaxDelay:     shared.Duration(time.Second),
+	})
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(3); got < 0 || got > 400*time.Millisecond {
+			t.Fatalf("backoff(3) = %v, want between 0 and 400ms", got)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"", 0, 0},
+		{"3", 3 * time.Second, 3 * time.Second},
+		{"0", 0, 0},
+		{"-5", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), 59 * time.Minute, time.Hour},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got < tt.min || got > tt.max {
+			t.Errorf("parseRetryAfter(%q) = %v, want between %v and %v", tt.value, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestRetryPolicyRun(t *testing.T) {
+	refused := &testNetError{err: syscall.ECONNREFUSED}
+
+	tests := []struct {
+		name      string
+		policy    RetryPolicy
+		outcomes  []interface{} // a status code or an error per attempt
+		attempts  int
+		wantErr   bool
+		wantDelay bool
+	}{
+		{"success", RetryPolicy{}, []interface{}{200}, 1, false, false},
+		{"retryable status then success", RetryPolicy{}, []interface{}{503, 200}, 2, false, true},
+		{"status that is not retried", RetryPolicy{}, []interface{}{500}, 1, false, false},
+		{"custom status", RetryPolicy{Statuses: []int{500}}, []interface{}{500, 500, 200}, 3, false, true},
+		{"attempts used up", RetryPolicy{MaxAttempts: 2}, []interface{}{503, 503, 200}, 2, false, true},
+		{"retryable error then success", RetryPolicy{}, []interface{}{refused, 200}, 2, false, true},
+		{"error that is not retried", RetryPolicy{Errors: []string{"timeout"}}, []interface{}{refused, 200}, 1, true, false},
+		{"error on every attempt", RetryPolicy{MaxAttempts: 2}, []interface{}{io.EOF, io.EOF}, 2, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.policy.InitialDelay = shared.Duration(time.Millisecond)
+			policy := mustRetryPolicy(t, tt.policy)
+
+			calls := 0
+			result, _, err := policy.run(context.Background(), func() (*SendResult, []byte, error) {
+				outcome := tt.outcomes[calls]
+				calls++
+				if err, ok := outcome.(error); ok {
+					return nil, nil, err
+				}
+				return &SendResult{StatusCode: outcome.(int), Headers: http.Header{}}, nil, nil
+			})
+
+			if calls != tt.attempts {
+				t.Errorf("made %d attempts, want %d", calls, tt.attempts)
+			}
+			var history []Attempt
+			if tt.wantErr {
+				var retryErr *RetryError
+				if !errors.As(err, &retryErr) {
+					t.Fatalf("got %v, want a RetryError", err)
+				}
+				history = retryErr.Attempts
+			} else {
+				if err != nil {
+					t.Fatalf("got %v, want a result", err)
+				}
+				history = result.Attempts
+			}
+			if len(history) != tt.attempts {
+				t.Fatalf("recorded %d attempts, want %d", len(history), tt.attempts)
+			}
+			if delayed := history[0].Delay > 0; delayed != tt.wantDelay {
+				t.Errorf("first attempt delay %v, want delayed %v", history[0].Delay, tt.wantDelay)
+			}
+			if last := history[len(history)-1]; last.Delay != 0 {
+				t.Errorf("last attempt has delay %v, want none", last.Delay)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRunHonorsRetryAfter(t *testing.T) {
+	tests := []struct {
+		name             string
+		ignoreRetryAfter bool
+		wantDelay        time.Duration
+		wantRetryAfter   bool
+	}{
+		{"capped by the maximum delay", false, 5 * time.Millisecond, true},
+		{"ignored", true, time.Millisecond, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := mustRetryPolicy(t, RetryPolicy{
+				Backoff:          BackoffConstant,
+				InitialDelay:     shared.Duration(time.Millisecond),
+				MaxDelay:         shared.Duration(5 * time.Millisecond),
+				IgnoreRetryAfter: tt.ignoreRetryAfter,
+			})
+			statuses := []int{http.StatusTooManyRequests, http.StatusOK}
+			calls := 0
+			result, _, err := policy.run(context.Background(), func() (*SendResult, []byte, error) {
+				status := statuses[calls]
+				calls++
+				return &SendResult{StatusCode: status, Headers: http.Header{"Retry-After": {"120"}}}, nil, nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			first := result.Attempts[0]
+			if first.Delay.Std() != tt.wantDelay || first.RetryAfter != tt.wantRetryAfter {
+				t.Errorf("got delay %v retry_after %v, want %v %v", first.Delay, first.RetryAfter, tt.wantDelay, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyRunStopsWhenCancelled(t *testing.T) {
+	policy := mustRetryPolicy(t, RetryPolicy{InitialDelay: shared.Duration(time.Hour), MaxDelay: shared.Duration(time.Hour)})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := policy.run(ctx, func() (*SendResult, []byte, error) {
+		return &SendResult{StatusCode: http.StatusServiceUnavailable, Headers: http.Header{}}, nil, nil
+	})
+	var retryErr *RetryError
+	if !errors.As(err, &retryErr) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want a cancelled RetryError", err)
+	}
+	if len(retryErr.Attempts) != 1 {
+		t.Errorf("recorded %d attempts, want 1", len(retryErr.Attempts))
+	}
+}
+
+func mustRetryPolicy(t *testing.T, policy RetryPolicy) *RetryPolicy {
+	t.Helper()
+	p, err := policy.withDefaults()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// testNetError wraps a syscall error the way the net package does
+type testNetError struct {
+	err error
+}
+
+func (e *testNetError) Error() string { return "dial tcp: " + e.err.Error() }
+func (e *testNetError) Unwrap() error { return e.err }
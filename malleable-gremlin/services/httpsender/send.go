@@ -2,9 +2,16 @@ package httpsender
 
 import (
 	"bytes"
-	"encoding/json"
+	"context"
+	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
 )
 
 type SendArgument struct {
@@ -12,67 +19,230 @@ type SendArgument struct {
 	Method  string            `json:"method"`
 	Headers map[string]string `json:"headers,omitempty"`
 	Body    interface{}       `json:"body,omitempty"`
+	// BodyMode is json (the default), raw (a string sent as is), base64 (binary data), form (an object
+	// sent URL-encoded) or multipart (a MultipartBody)
+	BodyMode string `json:"body_mode,omitempty"`
+	// MaxResponseSize bounds the response body kept, in bytes; longer bodies are truncated
+	MaxResponseSize int64 `json:"max_response_size,omitempty"`
+	// Assertions are checked against the response; the result then carries a verdict
+	Assertions *Assertions `json:"assertions,omitempty"`
+	// Retry repeats failed attempts; without it a single attempt is made
+	Retry *RetryPolicy `json:"retry,omitempty"`
+	ClientOptions
 }
 
 type SendResult struct {
-	StatusCode int               `json:"status_code"`
-	Headers    map[string]string `json:"headers"`
-	Body       interface{}       `json:"body"`
+	StatusCode int         `json:"status_code"`
+	Headers    http.Header `json:"headers"`
+	Trailers   http.Header `json:"trailers,omitempty"`
+	// Body is decoded JSON, text, or base64 when it is not valid UTF-8, as told by BodyEncoding
+	Body         interface{} `json:"body"`
+	BodyEncoding string      `json:"body_encoding"`
+	// BodySize is -1 when a truncated body was too long to measure
+	BodySize      int64 `json:"body_size"`
+	BodyTruncated bool  `json:"body_truncated,omitempty"`
+	// Proto is the protocol of the response, e.g. HTTP/1.1 or HTTP/2.0
+	Proto string `json:"proto"`
+	// Redirects lists the redirects followed, in order
+	Redirects []RedirectHop `json:"redirects,omitempty"`
+	// RedirectLimitReached is set when the response is a redirect that was not followed because of the limit
+	RedirectLimitReached bool        `json:"redirect_limit_reached,omitempty"`
+	TLS                  *TLSDetails `json:"tls,omitempty"`
+	Timing               *Timing     `json:"timing"`
+	// Attempts lists every attempt when the request has a retry policy
+	Attempts []Attempt `json:"attempts,omitempty"`
+	// Assertions and Verdict are only set when the request has assertions
+	Assertions []AssertionResult `json:"assertions,omitempty"`
+	Verdict    string            `json:"verdict,omitempty"`
 }
 
 func Send(req *SendArgument) (*SendResult, error) {
-	// Create a new request
-	httpReq, err := http.NewRequest(req.Method, req.URL, nil)
+	return SendContext(context.Background(), req)
+}
+
+// SendContext is Send bounded by ctx
+func SendContext(ctx context.Context, req *SendArgument) (*SendResult, error) {
+	result, _, err := sendContext(ctx, req)
+	return result, err
+}
+
+// sendContext is SendContext that also returns the response body as received
+func sendContext(ctx context.Context, req *SendArgument) (*SendResult, []byte, error) {
+	prepared, err := req.prepare()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	// Set headers
-	for k, v := range req.Headers {
-		httpReq.Header.Set(k, v)
+	transport, err := req.newTransport()
+	if err != nil {
+		return nil, nil, err
+	}
+	if transport != nil {
+		// The transport is only used for this request
+		defer transport.CloseIdleConnections()
+	}
+
+	client, err := req.newClient(transport)
+	if err != nil {
+		return nil, nil, err
 	}
+	return send(ctx, client, prepared)
+}
 
-	// Set body if present
-	if req.Body != nil {
-		bodyBytes, err := json.Marshal(req.Body)
-		if err != nil {
+// Validate checks the request, its body, assertions, retry policy and client options without sending it
+func (req *SendArgument) Validate() error {
+	if _, err := req.prepare(); err != nil {
+		return err
+	}
+	transport, err := req.newTransport()
+	if err != nil {
+		return err
+	}
+	if transport != nil {
+		transport.CloseIdleConnections()
+	}
+	_, err = req.newClient(transport)
+	return err
+}
+
+// preparedSend holds what sends derive from a SendArgument. The argument itself is never modified, so
+// that one argument can serve concurrent sends, and a load test checks and encodes it only once.
+type preparedSend struct {
+	req             *SendArgument
+	body            []byte
+	contentType     string
+	maxResponseSize int64
+	assertions      *Assertions
+	retry           *RetryPolicy
+}
+
+// prepare checks the request and derives everything a send needs
+func (req *SendArgument) prepare() (*preparedSend, error) {
+	p := &preparedSend{req: req, maxResponseSize: req.MaxResponseSize}
+	if p.maxResponseSize < 0 {
+		return nil, fmt.Errorf("%w: max_response_size must not be negative", ErrInvalidSendArgument)
+	} else if p.maxResponseSize == 0 {
+		p.maxResponseSize = defaultMaxResponseSize
+	}
+
+	var err error
+	if req.Assertions != nil {
+		if p.assertions, err = req.Assertions.compile(); err != nil {
+			return nil, err
+		}
+	}
+	if req.Retry != nil {
+		if p.retry, err = req.Retry.withDefaults(); err != nil {
 			return nil, err
 		}
-		httpReq.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
-		httpReq.ContentLength = int64(len(bodyBytes))
 	}
 
-	// Send the request
-	client := &http.Client{}
-	resp, err := client.Do(httpReq)
-	if err != nil {
+	// Encode the body first so that it can be sent again on 307 and 308 redirects and by every retry
+	if p.body, p.contentType, err = req.encodeBody(); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	u, err := url.Parse(req.URL)
 	if err != nil {
+		return nil, fmt.Errorf("%w: url: %v", ErrInvalidSendArgument, err)
+	}
+	guard, _ := currentEgress()
+	if err := guard.CheckScheme(u.Scheme); err != nil {
 		return nil, err
 	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%w: url scheme must be http or https", ErrInvalidSendArgument)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%w: url has no host", ErrInvalidSendArgument)
+	}
+	return p, nil
+}
+
+// send issues a prepared request with client and also returns the response body as received; load tests
+// share one client so that connections are pooled
+func send(ctx context.Context, client *http.Client, p *preparedSend) (*SendResult, []byte, error) {
+	result, respBody, err := p.retry.run(ctx, func() (*SendResult, []byte, error) {
+		return sendOnce(ctx, client, p)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if p.assertions != nil {
+		result.Assertions = p.assertions.evaluate(result, respBody)
+		result.Verdict = verdict(result.Assertions)
+	}
+	return result, respBody, nil
+}
+
+// sendOnce makes a single attempt and returns its result along with the body as received
+func sendOnce(ctx context.Context, client *http.Client, p *preparedSend) (*SendResult, []byte, error) {
+	req := p.req
+	var body io.Reader
+	if p.body != nil {
+		body = bytes.NewReader(p.body)
+	}
+
+	// Create a new request traced from DNS lookup to the end of the body
+	t := newTimer()
+	chain := &redirectChain{}
+	ctx = context.WithValue(httptrace.WithClientTrace(ctx, t.trace()), redirectChainKey{}, chain)
+	httpReq, err := http.NewRequestWithContext(ctx, req.Method, req.URL, body)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	// Parse response headers
-	headers := make(map[string]string)
-	for k, v := range resp.Header {
-		if len(v) > 0 {
-			headers[k] = v[0]
+	// Set headers
+	if p.contentType != "" {
+		httpReq.Header.Set("Content-Type", p.contentType)
+	}
+	for k, v := range req.Headers {
+		// A multipart boundary is generated with the body, so its content type cannot be replaced
+		if req.BodyMode == BodyModeMultipart && http.CanonicalHeaderKey(k) == "Content-Type" {
+			continue
 		}
+		httpReq.Header.Set(k, v)
 	}
 
-	// Try to parse body as JSON if possible
-	var bodyInterface interface{}
-	if err := json.Unmarshal(body, &bodyInterface); err != nil {
-		bodyInterface = string(body)
+	// Send the request
+	metrics.HTTPSenderRequestsInFlight.Inc()
+	start := time.Now()
+	resp, err := client.Do(httpReq)
+	metrics.HTTPSenderRequestsInFlight.Dec()
+	metrics.HTTPSenderRequestDuration.WithLabelValues(httpReq.Method).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.HTTPSenderRequestsTotal.WithLabelValues(httpReq.Method, "error").Inc()
+		return nil, nil, err
 	}
+	defer resp.Body.Close()
+	metrics.HTTPSenderRequestsTotal.WithLabelValues(httpReq.Method, strconv.Itoa(resp.StatusCode)).Inc()
+
+	if err := req.checkProtocol(resp); err != nil {
+		return nil, nil, err
+	}
+
+	// Read response body; trailers are only known once it has been read
+	respBody, size, truncated, err := readResponseBody(resp.Body, p.maxResponseSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	t.finish()
+
+	decoded, encoding := decodeResponseBody(respBody, truncated)
 
 	return &SendResult{
-		StatusCode: resp.StatusCode,
-		Headers:    headers,
-		Body:       bodyInterface,
-	}, nil
+		StatusCode:           resp.StatusCode,
+		Headers:              resp.Header,
+		Trailers:             resp.Trailer,
+		Body:                 decoded,
+		BodyEncoding:         encoding,
+		BodySize:             size,
+		BodyTruncated:        truncated,
+		Proto:                resp.Proto,
+		Redirects:            chain.hops,
+		RedirectLimitReached: chain.limited,
+		TLS:                  tlsDetails(resp.TLS),
+		Timing:               t.timing(),
+	}, respBody, nil
 }
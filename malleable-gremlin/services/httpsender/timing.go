@@ -0,0 +1,165 @@
+package httpsender
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// Timing breaks down where the time of a send went. When redirects are followed the phases
+// describe the last request of the chain, while Total covers the whole chain.
+type Timing struct {
+	DNSLookup       shared.Duration `json:"dns_lookup"`
+	TCPConnect      shared.Duration `json:"tcp_connect"`
+	TLSHandshake    shared.Duration `json:"tls_handshake"`
+	TimeToFirstByte shared.Duration `json:"time_to_first_byte"`
+	ContentTransfer shared.Duration `json:"content_transfer"`
+	Total           shared.Duration `json:"total"`
+	// ResolvedIPs lists the addresses returned by DNS, empty when the host is an IP or no lookup happened
+	ResolvedIPs []string `json:"resolved_ips,omitempty"`
+	// RemoteAddr is the address of the connection the response came from
+	RemoteAddr string `json:"remote_addr"`
+	ConnReused bool   `json:"conn_reused"`
+	// ConnIdleTime is how long a reused connection was idle in the pool
+	ConnIdleTime shared.Duration `json:"conn_idle_time"`
+}
+
+// ServerTiming formats the phases as a Server-Timing header value in milliseconds
+func (t *Timing) ServerTiming() string {
+	phases := []struct {
+		name string
+		d    shared.Duration
+	}{
+		{"dns", t.DNSLookup},
+		{"connect", t.TCPConnect},
+		{"tls", t.TLSHandshake},
+		{"ttfb", t.TimeToFirstByte},
+		{"transfer", t.ContentTransfer},
+		{"total", t.Total},
+	}
+
+	parts := make([]string, 0, len(phases))
+	for _, p := range phases {
+		parts = append(parts, fmt.Sprintf("%s;dur=%.3f", p.name, float64(p.d.Std())/float64(time.Millisecond)))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// timer collects httptrace events. Dialing may report from several goroutines, hence the mutex.
+type timer struct {
+	mu sync.Mutex
+
+	start        time.Time
+	requestStart time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	firstByte    time.Time
+	done         time.Time
+
+	resolvedIPs []string
+	remoteAddr  string
+	reused      bool
+	idleTime    time.Duration
+}
+
+func newTimer() *timer {
+	now := time.Now()
+	return &timer{start: now, requestStart: now}
+}
+
+// trace returns hooks recording into t
+func (t *timer) trace() *httptrace.ClientTrace {
+	record := func(f func(now time.Time)) {
+		now := time.Now()
+		t.mu.Lock()
+		f(now)
+		t.mu.Unlock()
+	}
+
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			// Every request of a redirect chain starts over
+			record(func(now time.Time) {
+				t.requestStart = now
+				t.dnsStart, t.dnsDone = time.Time{}, time.Time{}
+				t.connectStart, t.connectDone = time.Time{}, time.Time{}
+				t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+				t.resolvedIPs = nil
+			})
+		},
+		DNSStart: func(httptrace.DNSStartInfo) { record(func(now time.Time) { t.dnsStart = now }) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			record(func(now time.Time) {
+				t.dnsDone = now
+				for _, addr := range info.Addrs {
+					t.resolvedIPs = append(t.resolvedIPs, addr.IP.String())
+				}
+			})
+		},
+		ConnectStart: func(string, string) {
+			// Happy eyeballs may dial several addresses; the first start counts
+			record(func(now time.Time) {
+				if t.connectStart.IsZero() {
+					t.connectStart = now
+				}
+			})
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				record(func(now time.Time) { t.connectDone = now })
+			}
+		},
+		TLSHandshakeStart: func() { record(func(now time.Time) { t.tlsStart = now }) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { record(func(now time.Time) { t.tlsDone = now }) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			record(func(time.Time) {
+				t.remoteAddr = info.Conn.RemoteAddr().String()
+				t.reused = info.Reused
+				t.idleTime = info.IdleTime
+			})
+		},
+		GotFirstResponseByte: func() { record(func(now time.Time) { t.firstByte = now }) },
+	}
+}
+
+// finish marks the end of the body transfer
+func (t *timer) finish() {
+	t.mu.Lock()
+	t.done = time.Now()
+	t.mu.Unlock()
+}
+
+// timing computes the phase durations
+func (t *timer) timing() *Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	between := func(from, to time.Time) shared.Duration {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return 0
+		}
+		return shared.Duration(to.Sub(from))
+	}
+
+	return &Timing{
+		DNSLookup:       between(t.dnsStart, t.dnsDone),
+		TCPConnect:      between(t.connectStart, t.connectDone),
+		TLSHandshake:    between(t.tlsStart, t.tlsDone),
+		TimeToFirstByte: between(t.requestStart, t.firstByte),
+		ContentTransfer: between(t.firstByte, t.done),
+		Total:           between(t.start, t.done),
+		ResolvedIPs:     t.resolvedIPs,
+		RemoteAddr:      t.remoteAddr,
+		ConnReused:      t.reused,
+		ConnIdleTime:    shared.Duration(t.idleTime),
+	}
+}
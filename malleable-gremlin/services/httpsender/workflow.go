@@ -0,0 +1,345 @@
+package httpsender
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+	"github.com/eyadmba/malleable-gremlin/services/internal/jsonpath"
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// maxWorkflowSteps bounds the steps of a workflow
+const maxWorkflowSteps = 50
+
+// StepSkipped is the verdict of a step not run because an earlier one failed
+const StepSkipped = "skipped"
+
+// extractedPlaceholder stands in for values extracted at run time when steps are checked up front;
+// it is valid in URLs, header values and base64 bodies
+const extractedPlaceholder = "AAAA"
+
+// templateRef matches a {{name}} reference to a workflow variable
+var templateRef = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+// WorkflowArgument is an ordered list of requests where later steps use values extracted from earlier responses
+type WorkflowArgument struct {
+	Steps []WorkflowStep `json:"steps"`
+	// Variables are available to every step, e.g. credentials
+	Variables map[string]string `json:"variables,omitempty"`
+	// ContinueOnFailure runs the remaining steps after a failed one instead of skipping them
+	ContinueOnFailure bool `json:"continue_on_failure,omitempty"`
+}
+
+// WorkflowStep is a request whose URL, header values and body strings may reference variables as {{name}}
+type WorkflowStep struct {
+	Name    string       `json:"name,omitempty"`
+	Request SendArgument `json:"request"`
+	// Extract sets variables from the response for the following steps
+	Extract map[string]Extractor `json:"extract,omitempty"`
+}
+
+// Extractor takes a value from a response; exactly one source is set
+type Extractor struct {
+	// JSONPath addresses a value of a JSON body; values other than strings are extracted as JSON
+	JSONPath string `json:"json_path,omitempty"`
+	// Header is the name of a response header
+	Header string `json:"header,omitempty"`
+	// Regex is matched against the body as received; the first capture group is extracted, or the whole match
+	Regex string `json:"regex,omitempty"`
+
+	path  *jsonpath.Path
+	regex *regexp.Regexp
+}
+
+// WorkflowStepResult is the outcome of one step
+type WorkflowStepResult struct {
+	Name      string            `json:"name"`
+	Method    string            `json:"method,omitempty"`
+	URL       string            `json:"url,omitempty"`
+	Result    *SendResult       `json:"result,omitempty"`
+	Extracted map[string]string `json:"extracted,omitempty"`
+	Error     string            `json:"error,omitempty"`
+	// Verdict is pass, fail or skipped
+	Verdict string `json:"verdict"`
+}
+
+// WorkflowReport aggregates the steps of a workflow
+type WorkflowReport struct {
+	Steps []WorkflowStepResult `json:"steps"`
+	// Variables are the initial and extracted variables once the workflow ended
+	Variables map[string]string `json:"variables"`
+	Passed    int               `json:"passed"`
+	Failed    int               `json:"failed"`
+	Skipped   int               `json:"skipped"`
+	Duration  shared.Duration   `json:"duration"`
+	Verdict   string            `json:"verdict"`
+}
+
+// validate names the steps, compiles the extractors, checks that every referenced variable is set and
+// checks each step's request, so that a malformed step fails the workflow before any request is sent
+func (a *WorkflowArgument) validate() error {
+	if len(a.Steps) == 0 || len(a.Steps) > maxWorkflowSteps {
+		return fmt.Errorf("%w: between 1 and %d steps are required", ErrInvalidWorkflow, maxWorkflowSteps)
+	}
+
+	values := make(map[string]string, len(a.Variables))
+	for name, value := range a.Variables {
+		values[name] = value
+	}
+	for i := range a.Steps {
+		step := &a.Steps[i]
+		if step.Name == "" {
+			step.Name = fmt.Sprintf("step-%d", i+1)
+		}
+		if step.Request.URL == "" {
+			return fmt.Errorf("%w: step %s: request.url is required", ErrInvalidWorkflow, step.Name)
+		}
+
+		for _, ref := range step.Request.references() {
+			if _, ok := values[ref]; !ok {
+				return fmt.Errorf("%w: step %s: variable '%s' is not set by an earlier step", ErrInvalidWorkflow, step.Name, ref)
+			}
+		}
+		req := step.resolve(values)
+		if err := req.Validate(); err != nil {
+			var denied *egress.DeniedError
+			if errors.As(err, &denied) {
+				return err
+			}
+			return fmt.Errorf("%w: step %s: %v", ErrInvalidWorkflow, step.Name, err)
+		}
+
+		for name, extractor := range step.Extract {
+			if err := extractor.compile(); err != nil {
+				return fmt.Errorf("%w: step %s: extract %s: %v", ErrInvalidWorkflow, step.Name, name, err)
+			}
+			step.Extract[name] = extractor
+			values[name] = extractedPlaceholder
+		}
+	}
+	return nil
+}
+
+func (e *Extractor) compile() error {
+	sources := 0
+	if e.JSONPath != "" {
+		sources++
+		p, err := jsonpath.Parse(e.JSONPath)
+		if err != nil {
+			return err
+		}
+		e.path = p
+	}
+	if e.Header != "" {
+		sources++
+	}
+	if e.Regex != "" {
+		sources++
+		re, err := regexp.Compile(e.Regex)
+		if err != nil {
+			return err
+		}
+		e.regex = re
+	}
+	if sources != 1 {
+		return fmt.Errorf("exactly one of json_path, header or regex is required")
+	}
+	return nil
+}
+
+// extract returns the value from a response; rawBody is the body as received
+func (e *Extractor) extract(result *SendResult, rawBody []byte) (string, error) {
+	switch {
+	case e.path != nil:
+		value, found := e.path.Lookup(result.Body)
+		if !found {
+			return "", fmt.Errorf("JSON path %s not found in the response body", e.JSONPath)
+		}
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	case e.Header != "":
+		if values := result.Headers.Values(e.Header); len(values) > 0 {
+			return values[0], nil
+		}
+		return "", fmt.Errorf("header %s not found in the response", e.Header)
+	default:
+		match := e.regex.FindSubmatch(rawBody)
+		if match == nil {
+			return "", fmt.Errorf("regex %s does not match the response body", e.Regex)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	}
+}
+
+// RunWorkflow runs the steps in order; a failed step skips the remaining ones unless ContinueOnFailure is set.
+// A step fails when its variables cannot be resolved, its send fails, an assertion fails or an extraction finds nothing.
+func RunWorkflow(ctx context.Context, arg *WorkflowArgument) (*WorkflowReport, error) {
+	if err := arg.validate(); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	variables := make(map[string]string, len(arg.Variables))
+	for name, value := range arg.Variables {
+		variables[name] = value
+	}
+
+	report := &WorkflowReport{Steps: make([]WorkflowStepResult, 0, len(arg.Steps)), Variables: variables}
+	failed := false
+	for _, step := range arg.Steps {
+		if (failed && !arg.ContinueOnFailure) || ctx.Err() != nil {
+			report.Steps = append(report.Steps, WorkflowStepResult{Name: step.Name, Verdict: StepSkipped})
+			report.Skipped++
+			continue
+		}
+
+		stepResult := runStep(ctx, step, variables)
+		if stepResult.Verdict == VerdictFail {
+			failed = true
+			report.Failed++
+		} else {
+			report.Passed++
+		}
+		report.Steps = append(report.Steps, stepResult)
+	}
+
+	report.Duration = shared.Duration(time.Since(start))
+	report.Verdict = VerdictPass
+	if failed || report.Skipped > 0 {
+		report.Verdict = VerdictFail
+	}
+	return report, nil
+}
+
+// runStep resolves the templates of a step, sends it and adds the extracted values to variables
+func runStep(ctx context.Context, step WorkflowStep, variables map[string]string) WorkflowStepResult {
+	stepResult := WorkflowStepResult{Name: step.Name, Method: step.Request.Method, Verdict: VerdictFail}
+
+	// A variable can be missing when the step setting it failed and the workflow continued
+	for _, ref := range step.Request.references() {
+		if _, ok := variables[ref]; !ok {
+			stepResult.Error = fmt.Sprintf("variable '%s' is not set", ref)
+			return stepResult
+		}
+	}
+
+	req := step.resolve(variables)
+	stepResult.URL = req.URL
+
+	result, rawBody, err := sendContext(ctx, &req)
+	if err != nil {
+		stepResult.Error = err.Error()
+		return stepResult
+	}
+	stepResult.Result = result
+	if result.Verdict == VerdictFail {
+		stepResult.Error = "assertions failed"
+		return stepResult
+	}
+
+	// Extract in a stable order so that the first failure reported does not vary
+	names := make([]string, 0, len(step.Extract))
+	for name := range step.Extract {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		extractor := step.Extract[name]
+		value, err := extractor.extract(result, rawBody)
+		if err != nil {
+			stepResult.Error = fmt.Sprintf("extract %s: %v", name, err)
+			return stepResult
+		}
+		if stepResult.Extracted == nil {
+			stepResult.Extracted = make(map[string]string, len(names))
+		}
+		stepResult.Extracted[name] = value
+		variables[name] = value
+	}
+
+	stepResult.Verdict = VerdictPass
+	return stepResult
+}
+
+// resolve returns the request of the step with its templates expanded
+func (step *WorkflowStep) resolve(variables map[string]string) SendArgument {
+	req := step.Request
+	req.URL = expand(step.Request.URL, variables)
+
+	if len(req.Headers) > 0 {
+		req.Headers = make(map[string]string, len(step.Request.Headers))
+		for name, value := range step.Request.Headers {
+			req.Headers[name] = expand(value, variables)
+		}
+	}
+	req.Body = expandBody(req.Body, variables)
+	return req
+}
+
+// references lists the variables used by the templated parts of a request
+func (req *SendArgument) references() []string {
+	var refs []string
+	collect := func(s string) {
+		for _, match := range templateRef.FindAllStringSubmatch(s, -1) {
+			refs = append(refs, match[1])
+		}
+	}
+
+	collect(req.URL)
+	for _, value := range req.Headers {
+		collect(value)
+	}
+	walkStrings(req.Body, func(s string) string {
+		collect(s)
+		return s
+	})
+	return refs
+}
+
+// expand replaces {{name}} references with the values of the variables
+func expand(s string, variables map[string]string) string {
+	return templateRef.ReplaceAllStringFunc(s, func(ref string) string {
+		return variables[templateRef.FindStringSubmatch(ref)[1]]
+	})
+}
+
+// expandBody returns a copy of a body with every string expanded
+func expandBody(body interface{}, variables map[string]string) interface{} {
+	return walkStrings(body, func(s string) string {
+		return expand(s, variables)
+	})
+}
+
+// walkStrings returns a copy of a decoded JSON value with fn applied to every string
+func walkStrings(value interface{}, fn func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return fn(v)
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, member := range v {
+			copied[key] = walkStrings(member, fn)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, element := range v {
+			copied[i] = walkStrings(element, fn)
+		}
+		return copied
+	default:
+		return value
+	}
+}
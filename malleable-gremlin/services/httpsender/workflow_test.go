@@ -0,0 +1,274 @@
+package httpsender
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	variables := map[string]string{"token": "abc", "user.id": "42"}
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"plain", "plain"},
+		{"Bearer {{token}}", "Bearer abc"},
+		{"/users/{{ user.id }}/posts?t={{token}}", "/users/42/posts?t=abc"},
+		{"{{missing}}", ""},
+		{"{{not a ref}}", "{{not a ref}}"},
+		{"{token}", "{token}"},
+	}
+	for _, tt := range tests {
+		if got := expand(tt.in, variables); got != tt.want {
+			t.Errorf("expand(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestReferences(t *testing.T) {
+	req := SendArgument{
+		URL:     "http://example.com/{{tenant}}/items",
+		Headers: map[string]string{"Authorization": "Bearer {{token}}", "Accept": "application/json"},
+		Body: map[string]interface{}{
+			"owner": "{{user}}",
+			"tags":  []interface{}{"static", "{{ tag }}"},
+			"count": float64(3),
+		},
+	}
+	got := req.references()
+	sort.Strings(got)
+	want := []string{"tag", "tenant", "token", "user"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestWorkflowStepResolve(t *testing.T) {
+	step := WorkflowStep{Request: SendArgument{
+		URL:     "http://example.com/orders/{{id}}",
+		Headers: map[string]string{"X-Token": "{{token}}"},
+		Body:    map[string]interface{}{"items": []interface{}{"{{id}}", true}},
+	}}
+	variables := map[string]string{"id": "7", "token": "secret"}
+
+	req := step.resolve(variables)
+	if req.URL != "http://example.com/orders/7" {
+		t.Errorf("url = %q", req.URL)
+	}
+	if req.Headers["X-Token"] != "secret" {
+		t.Errorf("header = %q", req.Headers["X-Token"])
+	}
+	if want := map[string]interface{}{"items": []interface{}{"7", true}}; !reflect.DeepEqual(req.Body, want) {
+		t.Errorf("body = %v, want %v", req.Body, want)
+	}
+	if step.Request.Headers["X-Token"] != "{{token}}" || step.Request.Body.(map[string]interface{})["items"].([]interface{})[0] != "{{id}}" {
+		t.Error("resolve modified the step")
+	}
+}
+
+func TestWorkflowValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		arg  WorkflowArgument
+		want string
+	}{
+		{"no steps", WorkflowArgument{}, "between 1 and"},
+		{
+			name: "missing URL",
+			arg:  WorkflowArgument{Steps: []WorkflowStep{{Request: SendArgument{Method: "GET"}}}},
+			want: "step step-1: request.url is required",
+		},
+		{
+			name: "unknown variable",
+			arg:  WorkflowArgument{Steps: []WorkflowStep{{Name: "get", Request: SendArgument{URL: "http://example.com/{{id}}"}}}},
+			want: "step get: variable 'id' is not set by an earlier step",
+		},
+		{
+			name: "variable extracted by a later step",
+			arg: WorkflowArgument{Steps: []WorkflowStep{
+				{Request: SendArgument{URL: "http://example.com/{{id}}"}},
+				{Request: SendArgument{URL: "http://example.com"}, Extract: map[string]Extractor{"id": {Header: "X-Id"}}},
+			}},
+			want: "variable 'id' is not set",
+		},
+		{
+			name: "extractor without a source",
+			arg:  WorkflowArgument{Steps: []WorkflowStep{{Request: SendArgument{URL: "http://example.com"}, Extract: map[string]Extractor{"id": {}}}}},
+			want: "extract id: exactly one of",
+		},
+		{
+			name: "extractor with two sources",
+			arg: WorkflowArgument{Steps: []WorkflowStep{{
+				Request: SendArgument{URL: "http://example.com"},
+				Extract: map[string]Extractor{"id": {Header: "X-Id", Regex: "id=(\\d+)"}},
+			}}},
+			want: "extract id: exactly one of",
+		},
+		{
+			name: "bad extractor regex",
+			arg:  WorkflowArgument{Steps: []WorkflowStep{{Request: SendArgument{URL: "http://example.com"}, Extract: map[string]Extractor{"id": {Regex: "("}}}}},
+			want: "extract id:",
+		},
+		{
+			name: "malformed request in a later step",
+			arg: WorkflowArgument{Steps: []WorkflowStep{
+				{Request: SendArgument{URL: "http://example.com"}},
+				{Name: "bad", Request: SendArgument{URL: "http://example.com", BodyMode: "xml"}},
+			}},
+			want: "step bad:",
+		},
+		{
+			name: "URL that is only valid once expanded",
+			arg: WorkflowArgument{
+				Variables: map[string]string{"base": "not a url"},
+				Steps:     []WorkflowStep{{Request: SendArgument{URL: "{{base}}/items"}}},
+			},
+			want: "step step-1:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.arg.validate()
+			if !errors.Is(err, ErrInvalidWorkflow) {
+				t.Fatalf("got %v, want ErrInvalidWorkflow", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkflowValidateAcceptsVariables(t *testing.T) {
+	arg := WorkflowArgument{
+		Variables: map[string]string{"base": "http://example.com"},
+		Steps: []WorkflowStep{
+			{Request: SendArgument{URL: "{{base}}/login"}, Extract: map[string]Extractor{"token": {JSONPath: "$.token"}}},
+			{Request: SendArgument{URL: "{{base}}/me", Headers: map[string]string{"Authorization": "Bearer {{token}}"}}},
+		},
+	}
+	if err := arg.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if arg.Steps[1].Name != "step-2" {
+		t.Errorf("name = %q, want step-2", arg.Steps[1].Name)
+	}
+}
+
+func TestRunWorkflow(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("X-Session", "s-1")
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"token":"t-1","user":{"id":42}}`)
+		case "/users/42":
+			if r.Header.Get("Authorization") != "Bearer t-1" || r.Header.Get("X-Session") != "s-1" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			fmt.Fprint(w, "order=1001;")
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	login := WorkflowStep{
+		Name:    "login",
+		Request: SendArgument{URL: "{{base}}/login", Method: "POST"},
+		Extract: map[string]Extractor{
+			"token":   {JSONPath: "$.token"},
+			"user":    {JSONPath: "$.user.id"},
+			"session": {Header: "X-Session"},
+		},
+	}
+	profile := WorkflowStep{
+		Name: "profile",
+		Request: SendArgument{
+			URL:        "{{base}}/users/{{user}}",
+			Headers:    map[string]string{"Authorization": "Bearer {{token}}", "X-Session": "{{session}}"},
+			Assertions: &Assertions{Status: []int{200}},
+		},
+		Extract: map[string]Extractor{"order": {Regex: `order=(\d+)`}},
+	}
+	missing := WorkflowStep{Name: "missing", Request: SendArgument{URL: "{{base}}/missing", Assertions: &Assertions{Status: []int{200}}}}
+	last := WorkflowStep{Name: "last", Request: SendArgument{URL: "{{base}}/users/{{user}}"}}
+
+	tests := []struct {
+		name              string
+		steps             []WorkflowStep
+		continueOnFailure bool
+		verdicts          []string
+		variables         map[string]string
+	}{
+		{
+			name:      "variables flow between steps",
+			steps:     []WorkflowStep{login, profile},
+			verdicts:  []string{VerdictPass, VerdictPass},
+			variables: map[string]string{"token": "t-1", "user": "42", "session": "s-1", "order": "1001"},
+		},
+		{
+			name:     "a failed step skips the rest",
+			steps:    []WorkflowStep{login, missing, last},
+			verdicts: []string{VerdictPass, VerdictFail, StepSkipped},
+		},
+		{
+			name:              "continue on failure",
+			steps:             []WorkflowStep{login, missing, last},
+			continueOnFailure: true,
+			verdicts:          []string{VerdictPass, VerdictFail, VerdictPass},
+		},
+		{
+			name:              "a failed extraction leaves its variable unset",
+			steps:             []WorkflowStep{{Name: "bad", Request: SendArgument{URL: "{{base}}/login"}, Extract: map[string]Extractor{"user": {JSONPath: "$.nobody"}}}, last},
+			continueOnFailure: true,
+			verdicts:          []string{VerdictFail, VerdictFail},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arg := &WorkflowArgument{
+				Steps:             tt.steps,
+				Variables:         map[string]string{"base": server.URL},
+				ContinueOnFailure: tt.continueOnFailure,
+			}
+			report, err := RunWorkflow(context.Background(), arg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var verdicts []string
+			for _, step := range report.Steps {
+				verdicts = append(verdicts, step.Verdict)
+			}
+			if !reflect.DeepEqual(verdicts, tt.verdicts) {
+				t.Fatalf("verdicts %v, want %v (%+v)", verdicts, tt.verdicts, report.Steps)
+			}
+			for name, want := range tt.variables {
+				if got := report.Variables[name]; got != want {
+					t.Errorf("variable %s = %q, want %q", name, got, want)
+				}
+			}
+
+			wantVerdict := VerdictPass
+			for _, v := range tt.verdicts {
+				if v != VerdictPass {
+					wantVerdict = VerdictFail
+				}
+			}
+			if report.Verdict != wantVerdict {
+				t.Errorf("workflow verdict %q, want %q", report.Verdict, wantVerdict)
+			}
+		})
+	}
+}
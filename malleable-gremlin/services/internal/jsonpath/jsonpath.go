@@ -0,0 +1,139 @@
+// Package jsonpath evaluates the small subset of JSONPath needed to address values in decoded JSON
+// documents: an optional leading '$', dotted member names and [n] array indices, e.g. $.items[0].id.
+package jsonpath
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// step is either a member name or an array index
+type step struct {
+	name  string
+	index int
+	isIdx bool
+}
+
+// Path is a parsed JSONPath expression
+type Path struct {
+	raw   string
+	steps []step
+}
+
+// Parse compiles an expression such as $.user.roles[1] or user.name
+func Parse(expr string) (*Path, error) {
+	rest := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	p := &Path{raw: expr}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSON path '%s': empty member name", expr)
+			}
+			p.steps = append(p.steps, step{name: rest[:end]})
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path '%s': missing ']'", expr)
+			}
+			inner := rest[1:end]
+			if n, err := strconv.Atoi(inner); err == nil {
+				p.steps = append(p.steps, step{index: n, isIdx: true})
+			} else if unquoted, err := strconv.Unquote(strings.ReplaceAll(inner, "'", "\"")); err == nil {
+				p.steps = append(p.steps, step{name: unquoted})
+			} else {
+				return nil, fmt.Errorf("invalid JSON path '%s': bad subscript '%s'", expr, inner)
+			}
+			rest = rest[end+1:]
+		default:
+			// A leading member name without '$.'
+			if len(p.steps) > 0 {
+				return nil, fmt.Errorf("invalid JSON path '%s'", expr)
+			}
+			rest = "." + rest
+		}
+	}
+	return p, nil
+}
+
+// String returns the expression the path was parsed from
+func (p *Path) String() string {
+	return p.raw
+}
+
+// Normalized returns the path in the form built by Member and Element, e.g. $.items[0].id,
+// so that paths written differently can be compared as strings
+func (p *Path) Normalized() string {
+	normalized := "$"
+	for _, s := range p.steps {
+		if s.isIdx {
+			normalized = Element(normalized, s.index)
+		} else {
+			normalized = Member(normalized, s.name)
+		}
+	}
+	return normalized
+}
+
+// Member returns the path of the member name of the object at parent
+func Member(parent, name string) string {
+	if name == "" || strings.ContainsAny(name, ".[] ") {
+		return parent + "['" + name + "']"
+	}
+	return parent + "." + name
+}
+
+// Element returns the path of element i of the array at parent
+func Element(parent string, i int) string {
+	return parent + "[" + strconv.Itoa(i) + "]"
+}
+
+// Lookup returns the value at the path in a document decoded by encoding/json into interface{}.
+// Negative indices count from the end of an array.
+func (p *Path) Lookup(doc interface{}) (interface{}, bool) {
+	current := doc
+	for _, s := range p.steps {
+		if s.isIdx {
+			arr, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			idx := s.index
+			if idx < 0 {
+				idx += len(arr)
+			}
+			if idx < 0 || idx >= len(arr) {
+				return nil, false
+			}
+			current = arr[idx]
+			continue
+		}
+
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[s.name]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// Lookup parses expr and returns the value it addresses in doc
+func Lookup(doc interface{}, expr string) (interface{}, bool, error) {
+	p, err := Parse(expr)
+	if err != nil {
+		return nil, false, err
+	}
+	value, found := p.Lookup(doc)
+	return value, found, nil
+}
@@ -0,0 +1,115 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr       string
+		normalized string
+		err        string
+	}{
+		{"$", "$", ""},
+		{"", "$", ""},
+		{"$.user.name", "$.user.name", ""},
+		{"user.name", "$.user.name", ""},
+		{"  $.items[0].id ", "$.items[0].id", ""},
+		{"$.items[-1]", "$.items[-1]", ""},
+		{"$['first name']", "$['first name']", ""},
+		{`$["a.b"][2]`, "$['a.b'][2]", ""},
+		{"$[0][1]", "$[0][1]", ""},
+		{"$..name", "", "empty member name"},
+		{"$.user.", "", "empty member name"},
+		{"$.items[0", "", "missing ']'"},
+		{"$.items[x]", "", "bad subscript 'x'"},
+		{"$.items[0]name", "", "invalid JSON path"},
+	}
+
+	for _, tt := range tests {
+		p, err := Parse(tt.expr)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.expr, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got := p.Normalized(); got != tt.normalized {
+			t.Errorf("Parse(%q).Normalized() = %q, want %q", tt.expr, got, tt.normalized)
+		}
+		if p.String() != tt.expr {
+			t.Errorf("Parse(%q).String() = %q", tt.expr, p.String())
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var doc interface{}
+	data := `{"user":{"name":"ada","roles":["admin","dev"],"a.b":true},"items":[{"id":1},{"id":2}],"empty":null}`
+	if err := json.Unmarshal([]byte(data), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		expr  string
+		want  interface{}
+		found bool
+	}{
+		{"$", doc, true},
+		{"$.user.name", "ada", true},
+		{"user.roles[1]", "dev", true},
+		{"$.user.roles[-1]", "dev", true},
+		{"$.user['a.b']", true, true},
+		{"$.items[1].id", float64(2), true},
+		{"$.items", []interface{}{map[string]interface{}{"id": float64(1)}, map[string]interface{}{"id": float64(2)}}, true},
+		{"$.empty", nil, true},
+		{"$.user.age", nil, false},
+		{"$.user.roles[2]", nil, false},
+		{"$.user.roles[-3]", nil, false},
+		{"$.user[0]", nil, false},
+		{"$.items.id", nil, false},
+		{"$.user.name.first", nil, false},
+	}
+
+	for _, tt := range tests {
+		got, found, err := Lookup(doc, tt.expr)
+		if err != nil {
+			t.Errorf("Lookup(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if found != tt.found || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Lookup(%q) = %v, %v, want %v, %v", tt.expr, got, found, tt.want, tt.found)
+		}
+	}
+}
+
+func TestLookupRejectsInvalidExpressions(t *testing.T) {
+	if _, _, err := Lookup(map[string]interface{}{}, "$.items["); err == nil {
+		t.Error("got no error, want the expression to be rejected")
+	}
+}
+
+func TestMemberAndElement(t *testing.T) {
+	tests := []struct {
+		got  string
+		want string
+	}{
+		{Member("$", "id"), "$.id"},
+		{Member("$", "first name"), "$['first name']"},
+		{Member("$", "a.b"), "$['a.b']"},
+		{Member("$", ""), "$['']"},
+		{Element(Member("$", "items"), 3), "$.items[3]"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("got %q, want %q", tt.got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package stats
+
+import (
+	"math"
+	"math/rand/v2"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// maxSamples bounds the memory used by a Recorder; beyond it samples are kept by reservoir sampling
+const maxSamples = 100_000
+
+// LatencySummary describes a distribution of latencies
+type LatencySummary struct {
+	Count int64           `json:"count"`
+	Min   shared.Duration `json:"min"`
+	Mean  shared.Duration `json:"mean"`
+	P50   shared.Duration `json:"p50"`
+	P90   shared.Duration `json:"p90"`
+	P95   shared.Duration `json:"p95"`
+	P99   shared.Duration `json:"p99"`
+	Max   shared.Duration `json:"max"`
+}
+
+// Recorder collects latency samples from concurrent goroutines. Count, min, mean and max are exact;
+// percentiles are computed from a uniform sample of at most maxSamples observations.
+type Recorder struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	count   int64
+	sum     time.Duration
+	min     time.Duration
+	max     time.Duration
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// Observe records one latency sample
+func (r *Recorder) Observe(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.count++
+	r.sum += d
+	if r.count == 1 || d < r.min {
+		r.min = d
+	}
+	if d > r.max {
+		r.max = d
+	}
+
+	if len(r.samples) < maxSamples {
+		r.samples = append(r.samples, d)
+	} else if i := rand.Int64N(r.count); i < maxSamples {
+		r.samples[i] = d
+	}
+}
+
+// Summary computes the distribution of the recorded samples
+func (r *Recorder) Summary() LatencySummary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.count == 0 {
+		return LatencySummary{}
+	}
+
+	sorted := make([]time.Duration, len(r.samples))
+	copy(sorted, r.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	return LatencySummary{
+		Count: r.count,
+		Min:   shared.Duration(r.min),
+		Mean:  shared.Duration(r.sum / time.Duration(r.count)),
+		P50:   shared.Duration(percentile(sorted, 0.50)),
+		P90:   shared.Duration(percentile(sorted, 0.90)),
+		P95:   shared.Duration(percentile(sorted, 0.95)),
+		P99:   shared.Duration(percentile(sorted, 0.99)),
+		Max:   shared.Duration(r.max),
+	}
+}
+
+// percentile returns the nearest-rank percentile p (0 to 1) of sorted samples
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	// The rank is ceil(p*n); the epsilon keeps products such as 0.95*100 from rounding up a rank
+	rank := int(math.Ceil(p*float64(len(sorted))-1e-9)) - 1
+	rank = max(0, min(rank, len(sorted)-1))
+	return sorted[rank]
+}
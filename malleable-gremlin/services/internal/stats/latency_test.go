@@ -0,0 +1,102 @@
+package stats
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+func TestPercentile(t *testing.T) {
+	ms := func(values ...int) []time.Duration {
+		durations := make([]time.Duration, len(values))
+		for i, v := range values {
+			durations[i] = time.Duration(v) * time.Millisecond
+		}
+		return durations
+	}
+	hundred := make([]int, 100)
+	for i := range hundred {
+		hundred[i] = i + 1
+	}
+
+	tests := []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{"empty", nil, 0.5, 0},
+		{"single sample", ms(7), 0.99, 7 * time.Millisecond},
+		{"median of an odd count", ms(1, 2, 3), 0.5, 2 * time.Millisecond},
+		{"median of an even count", ms(1, 2, 3, 4), 0.5, 2 * time.Millisecond},
+		{"p90 rounds the rank up", ms(1, 2, 3, 4, 5, 6, 7), 0.9, 7 * time.Millisecond},
+		{"p90 of ten", ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 0.9, 9 * time.Millisecond},
+		{"p95 of ten", ms(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), 0.95, 10 * time.Millisecond},
+		{"p50 of a hundred", ms(hundred...), 0.5, 50 * time.Millisecond},
+		{"p95 of a hundred", ms(hundred...), 0.95, 95 * time.Millisecond},
+		{"p99 of a hundred", ms(hundred...), 0.99, 99 * time.Millisecond},
+		{"zero is the minimum", ms(1, 2, 3), 0, 1 * time.Millisecond},
+		{"one is the maximum", ms(1, 2, 3), 1, 3 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := percentile(tt.sorted, tt.p); got != tt.want {
+			t.Errorf("%s: percentile(%v) = %v, want %v", tt.name, tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestRecorderSummary(t *testing.T) {
+	r := NewRecorder()
+	if got := r.Summary(); got != (LatencySummary{}) {
+		t.Errorf("empty recorder summary = %+v, want zero", got)
+	}
+
+	// Observed out of order: the summary sorts the samples
+	for _, v := range []int{40, 10, 30, 20, 50, 100, 90, 60, 80, 70} {
+		r.Observe(time.Duration(v) * time.Millisecond)
+	}
+	got := r.Summary()
+	want := LatencySummary{
+		Count: 10,
+		Min:   durationMs(10),
+		Mean:  durationMs(55),
+		P50:   durationMs(50),
+		P90:   durationMs(90),
+		P95:   durationMs(100),
+		P99:   durationMs(100),
+		Max:   durationMs(100),
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestRecorderConcurrentObserve(t *testing.T) {
+	r := NewRecorder()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= 1000; i++ {
+				r.Observe(time.Duration(i) * time.Microsecond)
+			}
+		}()
+	}
+	wg.Wait()
+
+	got := r.Summary()
+	if got.Count != 8000 || got.Min.Std() != time.Microsecond || got.Max.Std() != 1000*time.Microsecond {
+		t.Errorf("got %+v, want 8000 samples between 1µs and 1ms", got)
+	}
+	if got.P50.Std() != 500*time.Microsecond {
+		t.Errorf("p50 = %v, want 500µs", got.P50)
+	}
+}
+
+func durationMs(ms int) shared.Duration {
+	return shared.Duration(time.Duration(ms) * time.Millisecond)
+}
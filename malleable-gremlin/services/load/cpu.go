@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
 )
 
 func GenerateCPULoad(ctx context.Context, tasks int, duration time.Duration) (*LoadResult, error) {
@@ -19,10 +21,14 @@ func GenerateCPULoad(ctx context.Context, tasks int, duration time.Duration) (*L
 	var wg sync.WaitGroup
 	wg.Add(tasks)
 
+	activeTasks := metrics.LoadActiveTasks.WithLabelValues("cpu")
+	activeTasks.Add(float64(tasks))
+
 	// Start CPU-intensive goroutines
 	for i := 0; i < tasks; i++ {
 		go func() {
 			defer wg.Done()
+			defer activeTasks.Dec()
 			deadline := time.Now().Add(duration)
 			for time.Now().Before(deadline) {
 				select {
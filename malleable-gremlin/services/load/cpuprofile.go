@@ -0,0 +1,173 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// dutyCycleSlice is the period over which each task alternates between busy and idle
+const dutyCycleSlice = 100 * time.Millisecond
+
+// CPU profile shapes
+const (
+	ShapeConstant = "constant"
+	ShapeRamp     = "ramp"
+	ShapeStep     = "step"
+	ShapeSine     = "sine"
+	ShapeSpike    = "spike"
+)
+
+// CPUProfile describes a CPU utilisation target per task that may change over time. Percentages
+// range from 0 to 100 per task, so 4 tasks at 35% load 1.4 cores in total.
+type CPUProfile struct {
+	Tasks    int             `json:"tasks"`
+	Duration shared.Duration `json:"duration"`
+	Shape    string          `json:"shape"`
+	// Percent is the target of the constant shape and the peak of the others
+	Percent float64 `json:"percent"`
+	// MinPercent is where ramp and step start, the trough of sine and the baseline between spikes
+	MinPercent float64 `json:"min_percent"`
+	// Steps is the number of equal increments of the step shape
+	Steps int `json:"steps,omitempty"`
+	// Period is the wavelength of sine and the interval between spikes
+	Period shared.Duration `json:"period,omitempty"`
+	// SpikeLength is how long each spike lasts
+	SpikeLength shared.Duration `json:"spike_length,omitempty"`
+}
+
+type CPUProfileResult struct {
+	Tasks    int             `json:"tasks"`
+	Shape    string          `json:"shape"`
+	Duration shared.Duration `json:"duration"`
+	// TargetPercent is the average requested utilisation per task over the run
+	TargetPercent float64 `json:"target_percent"`
+	// AchievedPercent is the average measured busy time per task over the run
+	AchievedPercent float64 `json:"achieved_percent"`
+}
+
+// Validate checks that the profile can be run
+func (p *CPUProfile) Validate() error {
+	if p.Tasks <= 0 {
+		return fmt.Errorf("number of tasks must be positive")
+	}
+	if p.Duration <= 0 {
+		return fmt.Errorf("duration must be positive")
+	}
+	if p.Percent < 0 || p.Percent > 100 || p.MinPercent < 0 || p.MinPercent > 100 {
+		return fmt.Errorf("percentages must be between 0 and 100")
+	}
+
+	switch p.Shape {
+	case ShapeConstant, ShapeRamp:
+	case ShapeStep:
+		if p.Steps <= 0 {
+			return fmt.Errorf("step shape requires a positive number of steps")
+		}
+	case ShapeSine:
+		if p.Period <= 0 {
+			return fmt.Errorf("sine shape requires a positive period")
+		}
+	case ShapeSpike:
+		if p.Period <= 0 || p.SpikeLength <= 0 || p.SpikeLength > p.Period {
+			return fmt.Errorf("spike shape requires a positive period and a spike length no longer than the period")
+		}
+	default:
+		return fmt.Errorf("unknown shape '%s', expected constant, ramp, step, sine or spike", p.Shape)
+	}
+	return nil
+}
+
+// targetAt returns the utilisation percentage requested at the given time into the run
+func (p *CPUProfile) targetAt(elapsed time.Duration) float64 {
+	progress := min(float64(elapsed)/float64(p.Duration), 1)
+	span := p.Percent - p.MinPercent
+
+	switch p.Shape {
+	case ShapeRamp:
+		return p.MinPercent + span*progress
+	case ShapeStep:
+		// Hold each of the steps for an equal share of the run, ending at Percent
+		step := min(math.Floor(progress*float64(p.Steps+1)), float64(p.Steps))
+		return p.MinPercent + span*step/float64(p.Steps)
+	case ShapeSine:
+		// Start at the trough
+		phase := 2 * math.Pi * float64(elapsed) / float64(p.Period)
+		return p.MinPercent + span*(1-math.Cos(phase))/2
+	case ShapeSpike:
+		if elapsed%p.Period.Std() < p.SpikeLength.Std() {
+			return p.Percent
+		}
+		return p.MinPercent
+	default:
+		return p.Percent
+	}
+}
+
+// GenerateCPUProfileLoad keeps each task busy for the targeted share of every duty cycle slice,
+// following the profile's shape until its duration elapses or ctx is cancelled
+func GenerateCPUProfileLoad(ctx context.Context, profile CPUProfile) (*CPUProfileResult, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, profile.Duration.Std())
+	defer cancel()
+
+	var (
+		wg      sync.WaitGroup
+		busy    atomic.Int64
+		planned atomic.Int64
+	)
+	activeTasks := metrics.LoadActiveTasks.WithLabelValues("cpu")
+	activeTasks.Add(float64(profile.Tasks))
+
+	start := time.Now()
+	wg.Add(profile.Tasks)
+	for i := 0; i < profile.Tasks; i++ {
+		go func() {
+			defer wg.Done()
+			defer activeTasks.Dec()
+
+			var targetCores float64
+			defer func() { metrics.LoadCPUTargetCores.Sub(targetCores) }()
+
+			for ctx.Err() == nil {
+				sliceStart := time.Now()
+				target := profile.targetAt(sliceStart.Sub(start)) / 100
+				metrics.LoadCPUTargetCores.Add(target - targetCores)
+				targetCores = target
+
+				busyFor := time.Duration(target * float64(dutyCycleSlice))
+				planned.Add(int64(busyFor))
+				for time.Since(sliceStart) < busyFor && ctx.Err() == nil {
+					// CPU-intensive operation
+					_ = 1 + 1
+				}
+				busy.Add(int64(time.Since(sliceStart)))
+
+				select {
+				case <-time.After(dutyCycleSlice - time.Since(sliceStart)):
+				case <-ctx.Done():
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	capacity := float64(elapsed) * float64(profile.Tasks)
+	return &CPUProfileResult{
+		Tasks:           profile.Tasks,
+		Shape:           profile.Shape,
+		Duration:        shared.Duration(elapsed),
+		TargetPercent:   float64(planned.Load()) / capacity * 100,
+		AchievedPercent: float64(busy.Load()) / capacity * 100,
+	}, nil
+}
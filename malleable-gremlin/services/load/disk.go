@@ -0,0 +1,291 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/eyadmba/malleable-gremlin/services/internal/stats"
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// directIOAlignment is the buffer, offset and block size alignment required by O_DIRECT
+const directIOAlignment = 4096
+
+// Disk access patterns
+const (
+	PatternSequential = "sequential"
+	PatternRandom     = "random"
+)
+
+// DiskConfig bounds the disk load requests may generate
+type DiskConfig struct {
+	// Directory is where test files are created; requests may only name its subdirectories
+	Directory string
+	// MaxFileSize and MaxBlockSize bound the test file and each read or write, in bytes
+	MaxFileSize  int64
+	MaxBlockSize int64
+	// MaxWorkers bounds the concurrent workers, each of which holds a block-sized buffer
+	MaxWorkers int
+}
+
+// Check reports options beyond the configured maximums as ErrInvalidDiskLoadOptions
+func (c DiskConfig) Check(o *DiskLoadOptions) error {
+	if o.FileSize > c.MaxFileSize {
+		return fmt.Errorf("%w: file size must be at most %d bytes", ErrInvalidDiskLoadOptions, c.MaxFileSize)
+	}
+	if o.BlockSize > c.MaxBlockSize {
+		return fmt.Errorf("%w: block size must be at most %d bytes", ErrInvalidDiskLoadOptions, c.MaxBlockSize)
+	}
+	if o.Workers > c.MaxWorkers {
+		return fmt.Errorf("%w: number of workers must be at most %d", ErrInvalidDiskLoadOptions, c.MaxWorkers)
+	}
+	return nil
+}
+
+type DiskLoadOptions struct {
+	Dir       string `json:"dir"`
+	BlockSize int64  `json:"block_size"`
+	// FileSize is the size of the test file the blocks are read from and written to
+	FileSize int64  `json:"file_size"`
+	Pattern  string `json:"pattern"`
+	// ReadRatio is the fraction of operations that are reads, from 0 (write only) to 1 (read only)
+	ReadRatio float64 `json:"read_ratio"`
+	Fsync     bool    `json:"fsync"`
+	Direct    bool    `json:"direct"`
+	// TotalBytes and Duration bound the run; whichever is reached first ends it
+	TotalBytes int64           `json:"total_bytes"`
+	Duration   shared.Duration `json:"duration"`
+	Workers    int             `json:"workers"`
+}
+
+type DiskLoadResult struct {
+	Duration        shared.Duration      `json:"duration"`
+	Operations      int64                `json:"operations"`
+	BytesRead       int64                `json:"bytes_read"`
+	BytesWritten    int64                `json:"bytes_written"`
+	ReadThroughput  float64              `json:"read_throughput_bytes_per_sec"`
+	WriteThroughput float64              `json:"write_throughput_bytes_per_sec"`
+	IOPS            float64              `json:"iops"`
+	ReadIOPS        float64              `json:"read_iops"`
+	WriteIOPS       float64              `json:"write_iops"`
+	ReadLatency     stats.LatencySummary `json:"read_latency"`
+	WriteLatency    stats.LatencySummary `json:"write_latency"`
+	Error           string               `json:"error,omitempty"`
+}
+
+// Validate reports options that cannot be run as ErrInvalidDiskLoadOptions
+func (o *DiskLoadOptions) Validate() error {
+	if err := o.validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidDiskLoadOptions, err)
+	}
+	return nil
+}
+
+func (o *DiskLoadOptions) validate() error {
+	if o.Dir == "" {
+		return fmt.Errorf("directory must be set")
+	}
+	if o.BlockSize <= 0 {
+		return fmt.Errorf("block size must be positive")
+	}
+	if o.FileSize < o.BlockSize {
+		return fmt.Errorf("file size must be at least one block")
+	}
+	if o.Pattern != PatternSequential && o.Pattern != PatternRandom {
+		return fmt.Errorf("pattern must be %s or %s", PatternSequential, PatternRandom)
+	}
+	if o.ReadRatio < 0 || o.ReadRatio > 1 {
+		return fmt.Errorf("read ratio must be between 0 and 1")
+	}
+	if o.TotalBytes <= 0 && o.Duration <= 0 {
+		return fmt.Errorf("total bytes or duration must be positive")
+	}
+	if o.TotalBytes < 0 || o.Duration < 0 {
+		return fmt.Errorf("total bytes and duration must not be negative")
+	}
+	if o.Workers <= 0 {
+		return fmt.Errorf("number of workers must be positive")
+	}
+	if o.Direct {
+		if directIOFlag == 0 {
+			return fmt.Errorf("direct I/O is not supported on this platform")
+		}
+		if o.BlockSize%directIOAlignment != 0 {
+			return fmt.Errorf("block size must be a multiple of %d bytes for direct I/O", directIOAlignment)
+		}
+	}
+	return nil
+}
+
+// GenerateDiskLoad reads and writes blocks of a test file created in opts.Dir until the
+// total bytes or duration is reached, and reports throughput, IOPS and latency percentiles
+func GenerateDiskLoad(ctx context.Context, opts DiskLoadOptions) (*DiskLoadResult, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Only whole blocks are accessed
+	blocks := opts.FileSize / opts.BlockSize
+	opts.FileSize = blocks * opts.BlockSize
+
+	path, err := createTestFile(ctx, opts.Dir, opts.FileSize, opts.BlockSize)
+	if err != nil {
+		return nil, fmt.Errorf("preparing test file: %w", err)
+	}
+	defer os.Remove(path)
+
+	flags := os.O_RDWR
+	if opts.Direct {
+		flags |= directIOFlag
+	}
+
+	if opts.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Duration.Std())
+		defer cancel()
+	}
+
+	var (
+		wg           sync.WaitGroup
+		reserved     atomic.Int64
+		bytesRead    atomic.Int64
+		bytesWritten atomic.Int64
+		errOnce      sync.Once
+		firstErr     error
+	)
+	readLatency := stats.NewRecorder()
+	writeLatency := stats.NewRecorder()
+	activeTasks := metrics.LoadActiveTasks.WithLabelValues("disk")
+
+	start := time.Now()
+	for w := 0; w < opts.Workers; w++ {
+		f, err := os.OpenFile(path, flags, 0)
+		if err != nil {
+			errOnce.Do(func() { firstErr = err })
+			break
+		}
+
+		wg.Add(1)
+		activeTasks.Inc()
+		go func() {
+			defer wg.Done()
+			defer activeTasks.Dec()
+			defer f.Close()
+
+			buf := alignedBuffer(opts.BlockSize)
+			for i := range buf {
+				buf[i] = byte(rand.IntN(256))
+			}
+
+			// Sequential workers start at evenly spread blocks
+			next := blocks * int64(w) / int64(opts.Workers)
+			for ctx.Err() == nil {
+				if opts.TotalBytes > 0 && reserved.Add(opts.BlockSize) > opts.TotalBytes {
+					return
+				}
+
+				block := next
+				if opts.Pattern == PatternRandom {
+					block = rand.Int64N(blocks)
+				} else {
+					next = (next + 1) % blocks
+				}
+				offset := block * opts.BlockSize
+
+				opStart := time.Now()
+				var opErr error
+				if rand.Float64() < opts.ReadRatio {
+					if _, opErr = f.ReadAt(buf, offset); opErr == nil {
+						readLatency.Observe(time.Since(opStart))
+						bytesRead.Add(opts.BlockSize)
+					}
+				} else {
+					_, opErr = f.WriteAt(buf, offset)
+					if opErr == nil && opts.Fsync {
+						opErr = f.Sync()
+					}
+					if opErr == nil {
+						writeLatency.Observe(time.Since(opStart))
+						bytesWritten.Add(opts.BlockSize)
+					}
+				}
+
+				if opErr != nil {
+					errOnce.Do(func() { firstErr = opErr })
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	result := &DiskLoadResult{
+		Duration:     shared.Duration(elapsed),
+		BytesRead:    bytesRead.Load(),
+		BytesWritten: bytesWritten.Load(),
+		ReadLatency:  readLatency.Summary(),
+		WriteLatency: writeLatency.Summary(),
+	}
+	result.Operations = result.ReadLatency.Count + result.WriteLatency.Count
+
+	if seconds := elapsed.Seconds(); seconds > 0 {
+		result.ReadThroughput = float64(result.BytesRead) / seconds
+		result.WriteThroughput = float64(result.BytesWritten) / seconds
+		result.IOPS = float64(result.Operations) / seconds
+		result.ReadIOPS = float64(result.ReadLatency.Count) / seconds
+		result.WriteIOPS = float64(result.WriteLatency.Count) / seconds
+	}
+	if firstErr != nil {
+		result.Error = firstErr.Error()
+	}
+
+	return result, nil
+}
+
+// createTestFile creates a file of the given size filled with random data so reads hit allocated blocks
+func createTestFile(ctx context.Context, dir string, size, blockSize int64) (string, error) {
+	f, err := os.CreateTemp(dir, "gremlin-disk-load-*")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	buf := make([]byte, blockSize)
+	for i := range buf {
+		buf[i] = byte(rand.IntN(256))
+	}
+	for written := int64(0); written < size; written += blockSize {
+		if err := ctx.Err(); err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+		if _, err := f.Write(buf); err != nil {
+			os.Remove(f.Name())
+			return "", err
+		}
+	}
+
+	if err := f.Sync(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// alignedBuffer returns a buffer whose start address is aligned as required by direct I/O
+func alignedBuffer(size int64) []byte {
+	buf := make([]byte, size+directIOAlignment)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) % directIOAlignment); rem != 0 {
+		offset = directIOAlignment - rem
+	}
+	return buf[offset : int64(offset)+size]
+}
@@ -0,0 +1,6 @@
+package load
+
+import "syscall"
+
+// directIOFlag bypasses the page cache when opening files
+const directIOFlag = syscall.O_DIRECT
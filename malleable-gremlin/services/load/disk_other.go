@@ -0,0 +1,6 @@
+//go:build !linux
+
+package load
+
+// directIOFlag is zero where O_DIRECT is not available; direct I/O requests are rejected
+const directIOFlag = 0
@@ -0,0 +1,14 @@
+package load
+
+import "errors"
+
+var (
+	// ErrJobNotFound indicates that no job exists with the given ID.
+	ErrJobNotFound = errors.New("job not found")
+
+	// ErrJobFinished indicates that a job cannot be cancelled because it already finished.
+	ErrJobFinished = errors.New("job already finished")
+
+	// ErrInvalidDiskLoadOptions indicates that disk load options failed validation.
+	ErrInvalidDiskLoadOptions = errors.New("invalid disk load options")
+)
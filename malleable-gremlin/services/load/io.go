@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"sync"
 	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
 )
 
 func GenerateIOLoad(ctx context.Context, tasks int, wait time.Duration, parallel int) (*LoadResult, error) {
@@ -26,13 +28,16 @@ func GenerateIOLoad(ctx context.Context, tasks int, wait time.Duration, parallel
 
 	startTime := time.Now()
 	ctxDone := ctx.Done()
+	activeTasks := metrics.LoadActiveTasks.WithLabelValues("io")
 
 	// Start IO-intensive goroutines
 	for i := 0; i < tasks; i++ {
 		select {
 		case semaphore <- struct{}{}:
+			activeTasks.Inc()
 			go func() {
 				defer func() {
+					activeTasks.Dec()
 					<-semaphore
 					wg.Done()
 				}()
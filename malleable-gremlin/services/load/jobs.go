@@ -0,0 +1,201 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// maxFinishedJobs bounds how many finished jobs are kept for inspection
+const maxFinishedJobs = 100
+
+// Job statuses
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// JobFunc generates load until it completes or ctx is cancelled
+type JobFunc func(ctx context.Context) (interface{}, error)
+
+// JobInfo is a snapshot of a load job
+type JobInfo struct {
+	ID               string          `json:"id"`
+	Kind             string          `json:"kind"`
+	Params           interface{}     `json:"params"`
+	Status           string          `json:"status"`
+	StartedAt        time.Time       `json:"started_at"`
+	FinishedAt       *time.Time      `json:"finished_at,omitempty"`
+	Elapsed          shared.Duration `json:"elapsed"`
+	ExpectedDuration shared.Duration `json:"expected_duration,omitempty"`
+	Progress         float64         `json:"progress"`
+	Result           interface{}     `json:"result,omitempty"`
+	Error            string          `json:"error,omitempty"`
+}
+
+type job struct {
+	info   JobInfo
+	cancel context.CancelFunc
+}
+
+// JobManager runs load generation in the background, independently of the request that started it
+type JobManager struct {
+	mu   sync.Mutex
+	jobs map[string]*job
+	wg   sync.WaitGroup
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewJobManager() *JobManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobManager{
+		jobs:   make(map[string]*job),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Start runs fn in the background and returns the new job immediately. expected is the
+// anticipated duration of the job, used to report progress; zero means unknown.
+func (m *JobManager) Start(kind string, params interface{}, expected time.Duration, fn JobFunc) *JobInfo {
+	ctx, cancel := context.WithCancel(m.ctx)
+
+	j := &job{
+		info: JobInfo{
+			ID:               shared.NewID("job_"),
+			Kind:             kind,
+			Params:           params,
+			Status:           JobRunning,
+			StartedAt:        time.Now(),
+			ExpectedDuration: shared.Duration(expected),
+		},
+		cancel: cancel,
+	}
+
+	m.mu.Lock()
+	m.jobs[j.info.ID] = j
+	info := j.snapshot()
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer cancel()
+
+		result, err := fn(ctx)
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		now := time.Now()
+		j.info.FinishedAt = &now
+		j.info.Result = result
+		switch {
+		case ctx.Err() != nil:
+			j.info.Status = JobCancelled
+		case err != nil:
+			j.info.Status = JobFailed
+			j.info.Error = err.Error()
+		default:
+			j.info.Status = JobSucceeded
+		}
+		m.pruneLocked()
+	}()
+
+	return info
+}
+
+// List returns a snapshot of every known job, most recent first
+func (m *JobManager) List() []*JobInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]*JobInfo, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		infos = append(infos, j.snapshot())
+	}
+	sort.Slice(infos, func(i, k int) bool { return infos[i].StartedAt.After(infos[k].StartedAt) })
+	return infos
+}
+
+// Get returns a snapshot of a job
+func (m *JobManager) Get(id string) (*JobInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrJobNotFound, id)
+	}
+	return j.snapshot(), nil
+}
+
+// Cancel stops a running job. The job is reported as cancelled once its load generation returns.
+func (m *JobManager) Cancel(id string) (*JobInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	j, ok := m.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%s'", ErrJobNotFound, id)
+	}
+	if j.info.FinishedAt != nil {
+		return nil, fmt.Errorf("%w: '%s' is %s", ErrJobFinished, id, j.info.Status)
+	}
+	j.cancel()
+	return j.snapshot(), nil
+}
+
+// Close cancels every running job and waits for them to stop
+func (m *JobManager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// pruneLocked drops the oldest finished jobs beyond maxFinishedJobs
+func (m *JobManager) pruneLocked() {
+	finished := make([]*job, 0, len(m.jobs))
+	for _, j := range m.jobs {
+		if j.info.FinishedAt != nil {
+			finished = append(finished, j)
+		}
+	}
+	if len(finished) <= maxFinishedJobs {
+		return
+	}
+
+	sort.Slice(finished, func(i, k int) bool { return finished[i].info.FinishedAt.Before(*finished[k].info.FinishedAt) })
+	for _, j := range finished[:len(finished)-maxFinishedJobs] {
+		delete(m.jobs, j.info.ID)
+	}
+}
+
+// snapshot copies the job info and computes its elapsed time and progress
+func (j *job) snapshot() *JobInfo {
+	info := j.info
+
+	if info.FinishedAt != nil {
+		info.Elapsed = shared.Duration(info.FinishedAt.Sub(info.StartedAt))
+		if info.Status == JobSucceeded {
+			info.Progress = 1
+		} else if info.ExpectedDuration > 0 {
+			info.Progress = min(float64(info.Elapsed)/float64(info.ExpectedDuration), 1)
+		}
+		return &info
+	}
+
+	info.Elapsed = shared.Duration(time.Since(info.StartedAt))
+	if info.ExpectedDuration > 0 {
+		// Never report a running job as complete
+		info.Progress = min(float64(info.Elapsed)/float64(info.ExpectedDuration), 0.99)
+	}
+	return &info
+}
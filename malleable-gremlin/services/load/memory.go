@@ -1,12 +1,15 @@
 package load
 
 import (
+	"context"
 	"fmt"
 	"runtime"
 	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
 )
 
-func GenerateMemoryLoad(size int64, gcAfter time.Duration) (*LoadResult, error) {
+func GenerateMemoryLoad(ctx context.Context, size int64, gcAfter time.Duration) (*LoadResult, error) {
 	if size <= 0 {
 		return nil, fmt.Errorf("memory size must be positive")
 	}
@@ -16,10 +19,16 @@ func GenerateMemoryLoad(size int64, gcAfter time.Duration) (*LoadResult, error)
 	for i := range data {
 		data[i] = byte(i % 256)
 	}
+	metrics.LoadMemoryBytes.Add(float64(size))
+	defer metrics.LoadMemoryBytes.Sub(float64(size))
 
 	// Wait for specified duration before GC
 	if gcAfter > 0 {
-		time.Sleep(gcAfter)
+		select {
+		case <-time.After(gcAfter):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
 	} else if gcAfter == 0 {
 		// Immediate GC
 		runtime.GC()
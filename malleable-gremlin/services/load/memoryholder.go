@@ -0,0 +1,312 @@
+package load
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
+)
+
+// memoryChunkSize is the allocation unit of held memory, which lets it be released gradually
+const memoryChunkSize = 1024 * 1024
+
+// pageSize is the stride used to touch allocated memory so it becomes resident
+const pageSize = 4096
+
+// Memory holder operations
+const (
+	MemoryIdle      = "idle"
+	MemoryGrowing   = "growing"
+	MemoryReleasing = "releasing"
+)
+
+type MemoryState struct {
+	HeldBytes int64  `json:"held_bytes"`
+	Chunks    int    `json:"chunks"`
+	Operation string `json:"operation"`
+	// RSSBytes is the resident set size of the whole process
+	RSSBytes      uint64 `json:"rss_bytes"`
+	HeapInUse     uint64 `json:"heap_in_use_bytes"`
+	HeapReleased  uint64 `json:"heap_released_bytes"`
+	OperationInfo string `json:"operation_info,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// MemoryHolder keeps memory allocated until it is explicitly released, optionally growing or
+// shrinking it gradually in the background. Only one background operation runs at a time.
+type MemoryHolder struct {
+	// opMu serialises starting and stopping background operations
+	opMu sync.Mutex
+
+	mu            sync.Mutex
+	chunks        [][]byte
+	held          int64
+	operation     string
+	operationInfo string
+	stop          context.CancelFunc
+	wg            sync.WaitGroup
+}
+
+func NewMemoryHolder() *MemoryHolder {
+	return &MemoryHolder{operation: MemoryIdle}
+}
+
+// Hold allocates size more bytes and keeps them until released
+func (h *MemoryHolder) Hold(size int64) (*MemoryState, error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("memory size must be positive")
+	}
+
+	h.mu.Lock()
+	h.allocateLocked(size)
+	h.mu.Unlock()
+
+	return h.State(), nil
+}
+
+// Grow allocates rate bytes per second in steps of interval, simulating a leak, until limit bytes are held
+// in total or the growth is stopped. A limit of zero grows until stopped.
+func (h *MemoryHolder) Grow(rate int64, interval time.Duration, limit int64) (*MemoryState, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("growth rate must be positive")
+	}
+	if interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive")
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+
+	perTick := max(int64(float64(rate)*interval.Seconds()), 1)
+	info := fmt.Sprintf("growing by %d bytes every %s", perTick, interval)
+	if limit > 0 {
+		info += fmt.Sprintf(" up to %d bytes", limit)
+	}
+
+	h.startOperation(MemoryGrowing, info, interval, func() bool {
+		if limit > 0 && h.held >= limit {
+			return false
+		}
+		step := perTick
+		if limit > 0 {
+			step = min(step, limit-h.held)
+		}
+		h.allocateLocked(step)
+		return limit == 0 || h.held < limit
+	})
+
+	return h.State(), nil
+}
+
+// Release frees size bytes, or everything held when size is zero. With more than one step the
+// memory is released in equal parts, one every interval.
+func (h *MemoryHolder) Release(size int64, steps int, interval time.Duration) (*MemoryState, error) {
+	if size < 0 {
+		return nil, fmt.Errorf("memory size must not be negative")
+	}
+	if steps > 1 && interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive when releasing in steps")
+	}
+
+	// Stop the operation in progress first, so that a last growth tick cannot land after the size is read
+	h.opMu.Lock()
+	defer h.opMu.Unlock()
+	h.stopOperation()
+
+	h.mu.Lock()
+	if size == 0 || size > h.held {
+		size = h.held
+	}
+	if steps <= 1 {
+		h.releaseLocked(size)
+		h.mu.Unlock()
+
+		returnToOS()
+		return h.State(), nil
+	}
+	h.mu.Unlock()
+
+	perStep := (size + int64(steps) - 1) / int64(steps)
+	remaining := size
+	info := fmt.Sprintf("releasing %d bytes every %s in %d steps", perStep, interval, steps)
+
+	h.startOperationLocked(MemoryReleasing, info, interval, func() bool {
+		step := min(perStep, remaining)
+		h.releaseLocked(step)
+		remaining -= step
+		return remaining > 0 && h.held > 0
+	})
+
+	return h.State(), nil
+}
+
+// Stop ends the background growth or release in progress, keeping what is currently held
+func (h *MemoryHolder) Stop() *MemoryState {
+	h.opMu.Lock()
+	h.stopOperation()
+	h.opMu.Unlock()
+
+	return h.State()
+}
+
+// stopOperation cancels the background operation and waits for it to end; opMu must be held
+func (h *MemoryHolder) stopOperation() {
+	h.mu.Lock()
+	stop := h.stop
+	h.mu.Unlock()
+
+	if stop != nil {
+		stop()
+	}
+	h.wg.Wait()
+}
+
+// State reports the held memory and the resident set size of the process
+func (h *MemoryHolder) State() *MemoryState {
+	h.mu.Lock()
+	state := &MemoryState{
+		HeldBytes:     h.held,
+		Chunks:        len(h.chunks),
+		Operation:     h.operation,
+		OperationInfo: h.operationInfo,
+	}
+	h.mu.Unlock()
+
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	state.HeapInUse = memStats.HeapInuse
+	state.HeapReleased = memStats.HeapReleased
+
+	proc, err := process.NewProcess(int32(os.Getpid()))
+	if err == nil {
+		var memInfo *process.MemoryInfoStat
+		if memInfo, err = proc.MemoryInfo(); err == nil {
+			state.RSSBytes = memInfo.RSS
+		}
+	}
+	if err != nil {
+		state.Error = fmt.Sprintf("reading RSS: %v", err)
+	}
+
+	return state
+}
+
+// Close stops any background operation and releases everything held
+func (h *MemoryHolder) Close() {
+	h.Stop()
+
+	h.mu.Lock()
+	h.releaseLocked(h.held)
+	h.mu.Unlock()
+}
+
+// startOperation replaces the current background operation with one calling tick every interval
+// while holding the lock, until tick returns false or the operation is stopped
+func (h *MemoryHolder) startOperation(operation, info string, interval time.Duration, tick func() bool) {
+	h.opMu.Lock()
+	defer h.opMu.Unlock()
+
+	h.startOperationLocked(operation, info, interval, tick)
+}
+
+// startOperationLocked is startOperation for callers holding opMu
+func (h *MemoryHolder) startOperationLocked(operation, info string, interval time.Duration, tick func() bool) {
+	h.stopOperation()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	h.mu.Lock()
+	h.operation = operation
+	h.operationInfo = info
+	h.stop = cancel
+	h.mu.Unlock()
+
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+		defer func() {
+			h.mu.Lock()
+			h.operation = MemoryIdle
+			h.operationInfo = ""
+			h.stop = nil
+			h.mu.Unlock()
+			cancel()
+		}()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			h.mu.Lock()
+			more := tick()
+			h.mu.Unlock()
+
+			if operation == MemoryReleasing {
+				returnToOS()
+			}
+			if !more {
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// allocateLocked adds size bytes of resident memory in chunks
+func (h *MemoryHolder) allocateLocked(size int64) {
+	for remaining := size; remaining > 0; {
+		n := min(remaining, memoryChunkSize)
+		chunk := make([]byte, n)
+		// Write to every page so the memory is actually resident
+		for i := 0; i < len(chunk); i += pageSize {
+			chunk[i] = byte(i/pageSize%255 + 1)
+		}
+		h.chunks = append(h.chunks, chunk)
+		remaining -= n
+	}
+
+	h.held += size
+	metrics.LoadMemoryBytes.Add(float64(size))
+}
+
+// releaseLocked drops size bytes, most recently allocated first
+func (h *MemoryHolder) releaseLocked(size int64) {
+	released := int64(0)
+	for released < size && len(h.chunks) > 0 {
+		last := h.chunks[len(h.chunks)-1]
+		want := size - released
+
+		if int64(len(last)) <= want {
+			h.chunks[len(h.chunks)-1] = nil
+			h.chunks = h.chunks[:len(h.chunks)-1]
+			released += int64(len(last))
+			continue
+		}
+
+		// Copy the part that is kept so the rest of the chunk can be collected
+		kept := make([]byte, int64(len(last))-want)
+		copy(kept, last)
+		h.chunks[len(h.chunks)-1] = kept
+		released += want
+	}
+
+	h.held -= released
+	metrics.LoadMemoryBytes.Sub(float64(released))
+}
+
+// returnToOS collects released chunks and hands the freed memory back to the operating system
+func returnToOS() {
+	debug.FreeOSMemory()
+}
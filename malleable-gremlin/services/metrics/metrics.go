@@ -0,0 +1,135 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+const namespace = "gremlin"
+
+// Registry holds every metric exposed by the server
+var Registry = prometheus.NewRegistry()
+
+var (
+	// HTTPRequestsTotal counts served requests by method, route pattern and status code
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests served, by method, route and status code.",
+	}, []string{"method", "route", "code"})
+
+	// HTTPRequestDuration observes the latency of served requests by method and route pattern
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of served HTTP requests, by method and route.",
+		Buckets:   []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300},
+	}, []string{"method", "route"})
+
+	// HTTPRequestsInFlight tracks requests currently being served
+	HTTPRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "Number of HTTP requests currently being served.",
+	})
+
+	// LoadActiveTasks tracks running load generation goroutines by kind (cpu, io)
+	LoadActiveTasks = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "load_active_tasks",
+		Help:      "Number of load generation tasks currently running, by kind.",
+	}, []string{"kind"})
+
+	// LoadCPUTargetCores tracks the CPU utilisation currently requested by CPU profiles, in cores
+	LoadCPUTargetCores = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "load_cpu_target_cores",
+		Help:      "CPU utilisation currently requested by CPU load profiles, in cores.",
+	})
+
+	// LoadMemoryBytes tracks the bytes currently held by memory load generation
+	LoadMemoryBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "load_memory_bytes",
+		Help:      "Bytes currently allocated and held by memory load generation.",
+	})
+
+	// PostgresStoredConnections tracks the connection strings held by the connection manager
+	PostgresStoredConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "postgresql_stored_connections",
+		Help:      "Number of stored PostgreSQL connection strings.",
+	})
+
+	// HTTPSenderRequestsTotal counts outbound requests by method and result (status code or "error")
+	HTTPSenderRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "httpsender_requests_total",
+		Help:      "Number of outbound HTTP requests sent, by method and result.",
+	}, []string{"method", "result"})
+
+	// HTTPSenderRequestDuration observes the latency of outbound requests by method
+	HTTPSenderRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "httpsender_request_duration_seconds",
+		Help:      "Latency of outbound HTTP requests, by method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// HTTPSenderRequestsInFlight tracks outbound requests currently waiting for a response
+	HTTPSenderRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "httpsender_requests_in_flight",
+		Help:      "Number of outbound HTTP requests currently in flight.",
+	})
+
+	// MonitorUp tracks whether the last check of each synthetic monitor succeeded (1) or not (0)
+	MonitorUp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "monitor_up",
+		Help:      "Whether the last check of a synthetic monitor succeeded, by monitor.",
+	}, []string{"monitor"})
+
+	// MonitorAvailability tracks the share of successful checks in each monitor's history
+	MonitorAvailability = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "monitor_availability_ratio",
+		Help:      "Share of successful checks in the stored history of a synthetic monitor, by monitor.",
+	}, []string{"monitor"})
+
+	// MonitorChecksTotal counts synthetic monitor checks by monitor and result (up or down)
+	MonitorChecksTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "monitor_checks_total",
+		Help:      "Number of synthetic monitor checks run, by monitor and result.",
+	}, []string{"monitor", "result"})
+
+	// MonitorCheckDuration observes the latency of synthetic monitor checks by monitor
+	MonitorCheckDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "monitor_check_duration_seconds",
+		Help:      "Latency of synthetic monitor checks, by monitor.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"monitor"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		LoadActiveTasks,
+		LoadCPUTargetCores,
+		LoadMemoryBytes,
+		PostgresStoredConnections,
+		HTTPSenderRequestsTotal,
+		HTTPSenderRequestDuration,
+		HTTPSenderRequestsInFlight,
+		MonitorUp,
+		MonitorAvailability,
+		MonitorChecksTotal,
+		MonitorCheckDuration,
+	)
+}
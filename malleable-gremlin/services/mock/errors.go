@@ -0,0 +1,11 @@
+package mock
+
+import "errors"
+
+var (
+	// ErrInvalidStub indicates that a stub definition cannot be registered
+	ErrInvalidStub = errors.New("invalid stub")
+
+	// ErrStubNotFound indicates that no stub exists with the given ID
+	ErrStubNotFound = errors.New("stub not found")
+)
@@ -0,0 +1,209 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// journalSize bounds how many journal entries are kept; older entries are dropped
+const journalSize = 1000
+
+// JournalEntry records how a request to the mock server was answered
+type JournalEntry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Query  string    `json:"query,omitempty"`
+	// StubID is empty when no stub matched
+	StubID        string `json:"stub_id,omitempty"`
+	ResponseIndex int    `json:"response_index"`
+	Status        int    `json:"status"`
+	// Failure is set when the stub's failure response was injected
+	Failure bool `json:"failure"`
+	Aborted bool `json:"aborted"`
+}
+
+// Outcome is the response chosen for a request
+type Outcome struct {
+	StubID        string
+	ResponseIndex int
+	Failure       bool
+	Response      Response
+}
+
+// Server holds the registered stubs and the journal of requests they answered
+type Server struct {
+	mu      sync.Mutex
+	stubs   []*Stub
+	journal []JournalEntry
+}
+
+func NewServer() *Server {
+	return &Server{}
+}
+
+// Add registers a stub
+func (s *Server) Add(stub *Stub) (*Stub, error) {
+	if err := stub.validate(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stub.ID = shared.NewID("stub_")
+	stub.Hits = 0
+	stub.next = 0
+	s.stubs = append(s.stubs, stub)
+	// A stable sort keeps registration order among equal priorities
+	sort.SliceStable(s.stubs, func(i, j int) bool { return s.stubs[i].Priority > s.stubs[j].Priority })
+
+	copied := *stub
+	return &copied, nil
+}
+
+// List returns the stubs in matching order
+func (s *Server) List() []*Stub {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stubs := make([]*Stub, 0, len(s.stubs))
+	for _, stub := range s.stubs {
+		copied := *stub
+		stubs = append(stubs, &copied)
+	}
+	return stubs
+}
+
+// Get returns one stub
+func (s *Server) Get(id string) (*Stub, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stub := range s.stubs {
+		if stub.ID == id {
+			copied := *stub
+			return &copied, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: '%s'", ErrStubNotFound, id)
+}
+
+// Delete removes a stub
+func (s *Server) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, stub := range s.stubs {
+		if stub.ID == id {
+			s.stubs = append(s.stubs[:i], s.stubs[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: '%s'", ErrStubNotFound, id)
+}
+
+// Reset removes every stub and clears the journal
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stubs = nil
+	s.journal = nil
+}
+
+// Journal returns the recorded requests, most recent first
+func (s *Server) Journal() []JournalEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]JournalEntry, len(s.journal))
+	for i, entry := range s.journal {
+		entries[len(s.journal)-1-i] = entry
+	}
+	return entries
+}
+
+// ClearJournal drops every journal entry
+func (s *Server) ClearJournal() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.journal = nil
+}
+
+// Match picks the response for a request and records it in the journal. path is the request path below
+// the module prefix. It returns nil when no stub matches.
+func (s *Server) Match(r *http.Request, path string, body []byte) *Outcome {
+	var decoded interface{}
+	if len(body) > 0 {
+		// Body matchers never match requests without a JSON body
+		if err := json.Unmarshal(body, &decoded); err != nil {
+			decoded = nil
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := JournalEntry{
+		Time:   time.Now(),
+		Method: r.Method,
+		Path:   path,
+		Query:  r.URL.RawQuery,
+		Status: http.StatusNotFound,
+	}
+	defer func() { s.record(entry) }()
+
+	for _, stub := range s.stubs {
+		if !stub.matches(r, path, decoded) {
+			continue
+		}
+
+		outcome := &Outcome{StubID: stub.ID}
+		if stub.FailureProbability > 0 && rand.Float64() < stub.FailureProbability {
+			outcome.Failure = true
+			outcome.ResponseIndex = -1
+			outcome.Response = *stub.Failure
+		} else {
+			idx := stub.next
+			if idx >= len(stub.Responses) {
+				if stub.Cycle {
+					idx %= len(stub.Responses)
+				} else {
+					idx = len(stub.Responses) - 1
+				}
+			}
+			outcome.ResponseIndex = idx
+			outcome.Response = stub.Responses[idx]
+		}
+		// Injected failures do not advance the sequence
+		if !outcome.Failure {
+			stub.next++
+		}
+		stub.Hits++
+
+		entry.StubID = stub.ID
+		entry.ResponseIndex = outcome.ResponseIndex
+		entry.Status = outcome.Response.Status
+		entry.Failure = outcome.Failure
+		entry.Aborted = outcome.Response.Abort
+		return outcome
+	}
+	return nil
+}
+
+// record appends to the journal, dropping the oldest entry when it is full. The caller holds s.mu.
+func (s *Server) record(entry JournalEntry) {
+	if len(s.journal) >= journalSize {
+		s.journal = append(s.journal[:0], s.journal[1:]...)
+	}
+	s.journal = append(s.journal, entry)
+}
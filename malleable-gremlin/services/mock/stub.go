@@ -0,0 +1,164 @@
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/eyadmba/malleable-gremlin/services/internal/jsonpath"
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+// Stub describes the requests a fake dependency answers and how it answers them
+type Stub struct {
+	ID string `json:"id"`
+	// Priority orders matching; higher priorities are tried first, equal priorities in registration order
+	Priority  int        `json:"priority"`
+	Request   Matcher    `json:"request"`
+	Responses []Response `json:"responses"`
+	// Cycle restarts the response sequence after the last response instead of repeating it
+	Cycle bool `json:"cycle,omitempty"`
+	// FailureProbability is the chance (0 to 1) of answering with Failure instead of the sequence
+	FailureProbability float64   `json:"failure_probability,omitempty"`
+	Failure            *Response `json:"failure,omitempty"`
+
+	// Hits counts the requests answered by the stub
+	Hits int64 `json:"hits"`
+
+	// next is the position in the response sequence
+	next      int
+	bodyPaths map[string]*jsonpath.Path
+}
+
+// Matcher selects requests; empty fields match everything
+type Matcher struct {
+	Method string `json:"method,omitempty"`
+	// Path is matched segment by segment below the module prefix: {name} or * matches one segment
+	// and a final {name...} matches the remaining segments, e.g. /users/{id}/orders/{rest...}
+	Path    string            `json:"path,omitempty"`
+	Query   map[string]string `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body maps JSON paths (e.g. $.user.id) to the value expected in the JSON request body
+	Body map[string]interface{} `json:"body,omitempty"`
+}
+
+// Response is what a stub sends back
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// Body is sent as is when it is a string and encoded as JSON otherwise
+	Body  interface{}     `json:"body,omitempty"`
+	Delay shared.Duration `json:"delay,omitempty"`
+	// Abort closes the connection without sending a response
+	Abort bool `json:"abort,omitempty"`
+}
+
+// validate checks the stub and fills in defaults
+func (s *Stub) validate() error {
+	if len(s.Responses) == 0 {
+		return fmt.Errorf("%w: at least one response is required", ErrInvalidStub)
+	}
+	for i := range s.Responses {
+		if err := s.Responses[i].validate(); err != nil {
+			return fmt.Errorf("%w: responses[%d]: %v", ErrInvalidStub, i, err)
+		}
+	}
+
+	if s.FailureProbability < 0 || s.FailureProbability > 1 {
+		return fmt.Errorf("%w: failure_probability must be between 0 and 1", ErrInvalidStub)
+	}
+	if s.Failure == nil {
+		s.Failure = &Response{Status: http.StatusServiceUnavailable}
+	} else if err := s.Failure.validate(); err != nil {
+		return fmt.Errorf("%w: failure: %v", ErrInvalidStub, err)
+	}
+
+	s.Request.Method = strings.ToUpper(s.Request.Method)
+	if s.Request.Path != "" && !strings.HasPrefix(s.Request.Path, "/") {
+		return fmt.Errorf("%w: request.path must start with '/'", ErrInvalidStub)
+	}
+	if idx := strings.Index(s.Request.Path, "...}"); idx >= 0 && idx+4 != len(s.Request.Path) {
+		return fmt.Errorf("%w: request.path may only end with a {name...} segment", ErrInvalidStub)
+	}
+
+	s.bodyPaths = make(map[string]*jsonpath.Path, len(s.Request.Body))
+	for expr := range s.Request.Body {
+		p, err := jsonpath.Parse(expr)
+		if err != nil {
+			return fmt.Errorf("%w: request.body: %v", ErrInvalidStub, err)
+		}
+		s.bodyPaths[expr] = p
+	}
+	return nil
+}
+
+func (r *Response) validate() error {
+	if r.Abort {
+		return nil
+	}
+	if r.Status == 0 {
+		r.Status = http.StatusOK
+	}
+	if r.Status < 100 || r.Status > 999 {
+		return fmt.Errorf("invalid status %d", r.Status)
+	}
+	if r.Delay < 0 {
+		return fmt.Errorf("delay must not be negative")
+	}
+	return nil
+}
+
+// matches reports whether the request, addressed by its path below the module prefix, satisfies the stub.
+// body is the decoded JSON request body, or nil when the body is not JSON.
+func (s *Stub) matches(r *http.Request, path string, body interface{}) bool {
+	m := s.Request
+	if m.Method != "" && m.Method != r.Method {
+		return false
+	}
+	if m.Path != "" && !matchPath(m.Path, path) {
+		return false
+	}
+
+	query := r.URL.Query()
+	for k, v := range m.Query {
+		if !query.Has(k) || query.Get(k) != v {
+			return false
+		}
+	}
+	for k, v := range m.Headers {
+		if r.Header.Get(k) != v {
+			return false
+		}
+	}
+
+	for expr, expected := range m.Body {
+		actual, found := s.bodyPaths[expr].Lookup(body)
+		if !found || !reflect.DeepEqual(actual, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPath compares a stub path pattern with a request path segment by segment
+func matchPath(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, seg := range patternSegments {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "...}") {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if seg == "*" || (strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}")) {
+			continue
+		}
+		if seg != pathSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
@@ -0,0 +1,196 @@
+package mock
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/users", "/users", true},
+		{"/users", "/users/", true},
+		{"/users", "/orders", false},
+		{"/users", "/users/7", false},
+		{"/users/{id}", "/users/7", true},
+		{"/users/{id}", "/users", false},
+		{"/users/*/orders", "/users/7/orders", true},
+		{"/users/*/orders", "/users/7/invoices", false},
+		{"/files/{rest...}", "/files/a/b/c.txt", true},
+		{"/files/{rest...}", "/files/a", true},
+		{"/files/{rest...}", "/files", true},
+		{"/files/{rest...}", "/other/a", false},
+		{"/", "/", true},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.path); got != tt.want {
+			t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestStubMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		matcher Matcher
+		method  string
+		target  string
+		headers map[string]string
+		body    string
+		want    bool
+	}{
+		{"empty matcher", Matcher{}, "DELETE", "/anything", nil, "", true},
+		{"method", Matcher{Method: "post"}, "POST", "/", nil, "", true},
+		{"other method", Matcher{Method: "post"}, "GET", "/", nil, "", false},
+		{"path", Matcher{Path: "/users/{id}"}, "GET", "/users/7", nil, "", true},
+		{"other path", Matcher{Path: "/users/{id}"}, "GET", "/orders/7", nil, "", false},
+		{"query", Matcher{Query: map[string]string{"page": "2"}}, "GET", "/?page=2&size=10", nil, "", true},
+		{"other query value", Matcher{Query: map[string]string{"page": "2"}}, "GET", "/?page=3", nil, "", false},
+		{"empty query value", Matcher{Query: map[string]string{"debug": ""}}, "GET", "/?debug", nil, "", true},
+		{"missing query parameter", Matcher{Query: map[string]string{"debug": ""}}, "GET", "/", nil, "", false},
+		{"header", Matcher{Headers: map[string]string{"x-tenant": "acme"}}, "GET", "/", map[string]string{"X-Tenant": "acme"}, "", true},
+		{"other header value", Matcher{Headers: map[string]string{"X-Tenant": "acme"}}, "GET", "/", map[string]string{"X-Tenant": "other"}, "", false},
+		{"body", Matcher{Body: map[string]interface{}{"$.user.id": float64(7)}}, "POST", "/", nil, `{"user":{"id":7}}`, true},
+		{"other body value", Matcher{Body: map[string]interface{}{"$.user.id": float64(7)}}, "POST", "/", nil, `{"user":{"id":8}}`, false},
+		{"missing body value", Matcher{Body: map[string]interface{}{"$.user.id": nil}}, "POST", "/", nil, `{"user":{}}`, false},
+		{"null body value", Matcher{Body: map[string]interface{}{"$.user.id": nil}}, "POST", "/", nil, `{"user":{"id":null}}`, true},
+		{"body that is not JSON", Matcher{Body: map[string]interface{}{"$.id": "7"}}, "POST", "/", nil, `id=7`, false},
+		{
+			name:    "every field",
+			matcher: Matcher{Method: "PUT", Path: "/users/{id}", Query: map[string]string{"dry": "1"}, Headers: map[string]string{"X-Tenant": "acme"}, Body: map[string]interface{}{"$.name": "ada"}},
+			method:  "PUT",
+			target:  "/users/7?dry=1",
+			headers: map[string]string{"X-Tenant": "acme"},
+			body:    `{"name":"ada"}`,
+			want:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer()
+			if _, err := server.Add(&Stub{Request: tt.matcher, Responses: []Response{{}}}); err != nil {
+				t.Fatal(err)
+			}
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			outcome := server.Match(r, r.URL.Path, []byte(tt.body))
+			if got := outcome != nil; got != tt.want {
+				t.Errorf("matched %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStubValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		stub Stub
+		want string
+	}{
+		{"no responses", Stub{}, "at least one response"},
+		{"bad status", Stub{Responses: []Response{{Status: 42}}}, "responses[0]: invalid status 42"},
+		{"negative delay", Stub{Responses: []Response{{Delay: -1}}}, "delay must not be negative"},
+		{"failure probability above one", Stub{Responses: []Response{{}}, FailureProbability: 1.5}, "failure_probability"},
+		{"bad failure response", Stub{Responses: []Response{{}}, Failure: &Response{Status: 1000}}, "failure: invalid status"},
+		{"relative path", Stub{Request: Matcher{Path: "users"}, Responses: []Response{{}}}, "must start with '/'"},
+		{"remainder segment in the middle", Stub{Request: Matcher{Path: "/files/{rest...}/x"}, Responses: []Response{{}}}, "{name...} segment"},
+		{"bad body path", Stub{Request: Matcher{Body: map[string]interface{}{"$.a[": 1}}, Responses: []Response{{}}}, "request.body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.stub.validate()
+			if !errors.Is(err, ErrInvalidStub) {
+				t.Fatalf("got %v, want ErrInvalidStub", err)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("got %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestStubValidateFillsDefaults(t *testing.T) {
+	stub := Stub{Request: Matcher{Method: "get"}, Responses: []Response{{}, {Abort: true}}}
+	if err := stub.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if stub.Request.Method != "GET" {
+		t.Errorf("method = %q, want GET", stub.Request.Method)
+	}
+	if stub.Responses[0].Status != http.StatusOK || stub.Responses[1].Status != 0 {
+		t.Errorf("statuses = %d, %d, want 200 and none for the abort", stub.Responses[0].Status, stub.Responses[1].Status)
+	}
+	if stub.Failure == nil || stub.Failure.Status != http.StatusServiceUnavailable {
+		t.Errorf("failure = %+v, want 503", stub.Failure)
+	}
+}
+
+func TestServerMatchOrderAndSequence(t *testing.T) {
+	tests := []struct {
+		name   string
+		stubs  []Stub
+		status []int
+	}{
+		{
+			name:   "sequence repeats its last response",
+			stubs:  []Stub{{Responses: []Response{{Status: 500}, {Status: 200}}}},
+			status: []int{500, 200, 200},
+		},
+		{
+			name:   "cycled sequence",
+			stubs:  []Stub{{Responses: []Response{{Status: 500}, {Status: 200}}, Cycle: true}},
+			status: []int{500, 200, 500},
+		},
+		{
+			name:   "higher priority wins",
+			stubs:  []Stub{{Responses: []Response{{Status: 201}}}, {Priority: 5, Responses: []Response{{Status: 202}}}},
+			status: []int{202, 202},
+		},
+		{
+			name:   "registration order among equal priorities",
+			stubs:  []Stub{{Responses: []Response{{Status: 201}}}, {Responses: []Response{{Status: 202}}}},
+			status: []int{201, 201},
+		},
+		{
+			name:   "certain failure",
+			stubs:  []Stub{{Responses: []Response{{Status: 200}}, FailureProbability: 1, Failure: &Response{Status: 502}}},
+			status: []int{502, 502},
+		},
+		{
+			name:   "unmatched requests",
+			stubs:  []Stub{{Request: Matcher{Path: "/other"}, Responses: []Response{{}}}},
+			status: []int{http.StatusNotFound},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer()
+			for i := range tt.stubs {
+				if _, err := server.Add(&tt.stubs[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, want := range tt.status {
+				r := httptest.NewRequest("GET", "/path", nil)
+				server.Match(r, r.URL.Path, nil)
+				if got := server.Journal()[0].Status; got != want {
+					t.Errorf("request %d: status %d, want %d", i+1, got, want)
+				}
+			}
+			if entries := len(server.Journal()); entries != len(tt.status) {
+				t.Errorf("journal has %d entries, want %d", entries, len(tt.status))
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package monitor
+
+import "errors"
+
+var (
+	// ErrInvalidMonitor indicates that a monitor has an invalid name, no URL or an interval below the configured minimum.
+	ErrInvalidMonitor = errors.New("invalid monitor")
+
+	// ErrTooManyMonitors indicates that the configured maximum number of monitors is reached.
+	ErrTooManyMonitors = errors.New("too many monitors")
+
+	// ErrMonitorExists indicates that a monitor with the same name already exists.
+	ErrMonitorExists = errors.New("monitor already exists")
+
+	// ErrMonitorNotFound indicates that no monitor exists with the given name.
+	ErrMonitorNotFound = errors.New("monitor not found")
+)
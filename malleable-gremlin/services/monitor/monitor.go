@@ -0,0 +1,404 @@
+// Package monitor runs HTTP requests periodically as synthetic monitors and keeps their recent results
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+	"github.com/eyadmba/malleable-gremlin/services/httpsender"
+	"github.com/eyadmba/malleable-gremlin/services/internal/stats"
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+var monitorNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Config holds the limits of the monitors
+type Config struct {
+	MaxMonitors int
+	// History is how many checks each monitor keeps
+	History     int
+	MinInterval time.Duration
+}
+
+// MonitorArgument registers a request to be sent every interval
+type MonitorArgument struct {
+	Name     string                  `json:"name"`
+	Request  httpsender.SendArgument `json:"request"`
+	Interval shared.Duration         `json:"interval"`
+	// Paused registers the monitor without starting it
+	Paused bool `json:"paused,omitempty"`
+}
+
+// Check is the outcome of one run of a monitor
+type Check struct {
+	Time time.Time `json:"time"`
+	// Up is set when the send succeeded and either passed its assertions or, without assertions,
+	// got a status below 400
+	Up         bool            `json:"up"`
+	StatusCode int             `json:"status_code,omitempty"`
+	Verdict    string          `json:"verdict,omitempty"`
+	Error      string          `json:"error,omitempty"`
+	Duration   shared.Duration `json:"duration"`
+}
+
+// MonitorInfo is a snapshot of a monitor; availability and latency cover the stored checks
+type MonitorInfo struct {
+	Name string `json:"name"`
+	// Request has its credentials redacted since every client can list monitors
+	Request   httpsender.SendArgument `json:"request"`
+	Interval  shared.Duration         `json:"interval"`
+	Paused    bool                    `json:"paused"`
+	CreatedAt time.Time               `json:"created_at"`
+	// Checks counts every check run, including those evicted from the history
+	Checks int64 `json:"checks"`
+	Stored int   `json:"stored"`
+	// Availability is the percentage of stored checks that were up
+	Availability float64 `json:"availability"`
+	// Latency is the distribution of the stored checks that got a response
+	Latency             stats.LatencySummary `json:"latency"`
+	ConsecutiveFailures int                  `json:"consecutive_failures"`
+	LastCheck           *Check               `json:"last_check,omitempty"`
+}
+
+// monitor keeps its most recent checks in a ring buffer
+type monitor struct {
+	arg       MonitorArgument
+	createdAt time.Time
+	checks    []Check
+	next      int
+	stored    int
+	total     int64
+	failures  int
+
+	// cancel stops the running monitor and done is closed once it has stopped; both are nil while paused
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Manager runs the monitors in the background
+type Manager struct {
+	mu       sync.Mutex
+	monitors map[string]*monitor
+	config   Config
+	wg       sync.WaitGroup
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func NewManager(config Config) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{
+		monitors: make(map[string]*monitor),
+		config:   config,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Create registers a monitor and starts it unless it is paused; its first check runs immediately
+func (m *Manager) Create(arg MonitorArgument) (*MonitorInfo, error) {
+	if !monitorNamePattern.MatchString(arg.Name) {
+		return nil, fmt.Errorf("%w: name '%s' must be 1 to 64 letters, digits, '-' or '_'", ErrInvalidMonitor, arg.Name)
+	}
+	if arg.Request.URL == "" {
+		return nil, fmt.Errorf("%w: request.url is required", ErrInvalidMonitor)
+	}
+	if arg.Interval.Std() < m.config.MinInterval {
+		return nil, fmt.Errorf("%w: interval must be at least %s", ErrInvalidMonitor, m.config.MinInterval)
+	}
+	if arg.Request.Method == "" {
+		arg.Request.Method = http.MethodGet
+	}
+	if err := arg.Request.Validate(); err != nil {
+		var denied *egress.DeniedError
+		if errors.As(err, &denied) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: request: %v", ErrInvalidMonitor, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.monitors[arg.Name]; exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrMonitorExists, arg.Name)
+	}
+	if len(m.monitors) >= m.config.MaxMonitors {
+		return nil, fmt.Errorf("%w: at most %d may be registered", ErrTooManyMonitors, m.config.MaxMonitors)
+	}
+
+	mon := &monitor{
+		arg:       arg,
+		createdAt: time.Now(),
+		checks:    make([]Check, m.config.History),
+	}
+	m.monitors[arg.Name] = mon
+	if !arg.Paused {
+		m.start(mon)
+	}
+	return mon.info(), nil
+}
+
+// List returns every monitor ordered by name
+func (m *Manager) List() []*MonitorInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	infos := make([]*MonitorInfo, 0, len(m.monitors))
+	for _, mon := range m.monitors {
+		infos = append(infos, mon.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Get returns one monitor
+func (m *Manager) Get(name string) (*MonitorInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mon, exists := m.monitors[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrMonitorNotFound, name)
+	}
+	return mon.info(), nil
+}
+
+// History returns the stored checks of a monitor, most recent first; limit bounds how many, zero returns all
+func (m *Manager) History(name string, limit int) ([]Check, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mon, exists := m.monitors[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrMonitorNotFound, name)
+	}
+	checks := mon.newestFirst()
+	if limit > 0 && limit < len(checks) {
+		checks = checks[:limit]
+	}
+	return checks, nil
+}
+
+// Pause stops running a monitor, keeping its history; a check in progress is abandoned
+func (m *Manager) Pause(name string) (*MonitorInfo, error) {
+	m.mu.Lock()
+	mon, exists := m.monitors[name]
+	if !exists {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("%w: '%s'", ErrMonitorNotFound, name)
+	}
+	done := m.stop(mon)
+	m.mu.Unlock()
+
+	// The monitor records its checks under the lock, so it is awaited without holding it
+	if done != nil {
+		<-done
+	}
+	return m.Get(name)
+}
+
+// Resume starts a paused monitor again; resuming a running monitor has no effect
+func (m *Manager) Resume(name string) (*MonitorInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mon, exists := m.monitors[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrMonitorNotFound, name)
+	}
+	if mon.arg.Paused {
+		m.start(mon)
+	}
+	return mon.info(), nil
+}
+
+// Delete stops a monitor and removes it with its history and metrics
+func (m *Manager) Delete(name string) error {
+	m.mu.Lock()
+	mon, exists := m.monitors[name]
+	if !exists {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: '%s'", ErrMonitorNotFound, name)
+	}
+	delete(m.monitors, name)
+	done := m.stop(mon)
+	m.mu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+	metrics.MonitorUp.DeleteLabelValues(name)
+	metrics.MonitorAvailability.DeleteLabelValues(name)
+	metrics.MonitorChecksTotal.DeleteLabelValues(name, "up")
+	metrics.MonitorChecksTotal.DeleteLabelValues(name, "down")
+	metrics.MonitorCheckDuration.DeleteLabelValues(name)
+	return nil
+}
+
+// Close stops every monitor and waits for them to return
+func (m *Manager) Close() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// start runs a monitor in the background; the caller holds the lock
+func (m *Manager) start(mon *monitor) {
+	ctx, cancel := context.WithCancel(m.ctx)
+	mon.cancel = cancel
+	mon.done = make(chan struct{})
+	mon.arg.Paused = false
+
+	m.wg.Add(1)
+	go m.run(ctx, mon, mon.done)
+}
+
+// stop cancels a running monitor and returns the channel closed once it has returned; the caller holds the lock
+func (m *Manager) stop(mon *monitor) chan struct{} {
+	done := mon.done
+	if mon.cancel != nil {
+		mon.cancel()
+	}
+	mon.cancel = nil
+	mon.done = nil
+	mon.arg.Paused = true
+	return done
+}
+
+// run checks the monitor every interval until ctx is cancelled
+func (m *Manager) run(ctx context.Context, mon *monitor, done chan struct{}) {
+	defer m.wg.Done()
+	defer close(done)
+
+	interval := mon.arg.Interval.Std()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		check := mon.check(ctx, interval)
+		// A check cut short by pausing or deleting the monitor says nothing about the target
+		if ctx.Err() != nil {
+			return
+		}
+		m.record(mon, check)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// check sends the request once; without a timeout of its own it may not outlast the interval
+func (mon *monitor) check(ctx context.Context, interval time.Duration) Check {
+	req := &mon.arg.Request
+	if req.Timeout == 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, interval)
+		defer cancel()
+	}
+
+	check := Check{Time: time.Now()}
+	result, err := httpsender.SendContext(ctx, req)
+	check.Duration = shared.Duration(time.Since(check.Time))
+	if err != nil {
+		check.Error = err.Error()
+		return check
+	}
+
+	check.StatusCode = result.StatusCode
+	check.Verdict = result.Verdict
+	if result.Verdict != "" {
+		check.Up = result.Verdict == httpsender.VerdictPass
+	} else {
+		check.Up = result.StatusCode < http.StatusBadRequest
+	}
+	return check
+}
+
+// record stores a check, evicting the oldest one when the history is full, and updates the metrics
+func (m *Manager) record(mon *monitor, check Check) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	mon.checks[mon.next] = check
+	mon.next = (mon.next + 1) % len(mon.checks)
+	mon.stored = min(mon.stored+1, len(mon.checks))
+	mon.total++
+	if check.Up {
+		mon.failures = 0
+	} else {
+		mon.failures++
+	}
+
+	name := mon.arg.Name
+	up, result := 0.0, "down"
+	if check.Up {
+		up, result = 1, "up"
+	}
+	metrics.MonitorUp.WithLabelValues(name).Set(up)
+	metrics.MonitorChecksTotal.WithLabelValues(name, result).Inc()
+	metrics.MonitorCheckDuration.WithLabelValues(name).Observe(check.Duration.Std().Seconds())
+	metrics.MonitorAvailability.WithLabelValues(name).Set(mon.availability() / 100)
+}
+
+// availability returns the percentage of stored checks that were up
+func (mon *monitor) availability() float64 {
+	if mon.stored == 0 {
+		return 0
+	}
+	up := 0
+	for _, check := range mon.newestFirst() {
+		if check.Up {
+			up++
+		}
+	}
+	return float64(up) * 100 / float64(mon.stored)
+}
+
+func (mon *monitor) info() *MonitorInfo {
+	checks := mon.newestFirst()
+
+	latency := stats.NewRecorder()
+	for _, check := range checks {
+		if check.StatusCode != 0 {
+			latency.Observe(check.Duration.Std())
+		}
+	}
+
+	info := &MonitorInfo{
+		Name:                mon.arg.Name,
+		Request:             mon.arg.Request.Redacted(),
+		Interval:            mon.arg.Interval,
+		Paused:              mon.arg.Paused,
+		CreatedAt:           mon.createdAt,
+		Checks:              mon.total,
+		Stored:              mon.stored,
+		Availability:        mon.availability(),
+		Latency:             latency.Summary(),
+		ConsecutiveFailures: mon.failures,
+	}
+	if len(checks) > 0 {
+		info.LastCheck = &checks[0]
+	}
+	return info
+}
+
+func (mon *monitor) newestFirst() []Check {
+	checks := make([]Check, 0, mon.stored)
+	for i := 1; i <= mon.stored; i++ {
+		idx := (mon.next - i + len(mon.checks)) % len(mon.checks)
+		checks = append(checks, mon.checks[idx])
+	}
+	return checks
+}
@@ -3,14 +3,12 @@ package postgresql
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/eyadmba/malleable-gremlin/services/egress"
 )
 
-var connectTimeout = 10 * time.Second
-
 type ConnectResult struct {
 	Success bool   `json:"success"`
 	Error   string `json:"error,omitempty"`
@@ -43,17 +41,22 @@ func (cm *ConnectionManager) Connect(ctx context.Context, connStr, connID string
 	}
 
 	// Open a new connection using the determined string
-	db, err = sql.Open("postgres", actualConnStr)
+	db, err = cm.open(actualConnStr)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", ErrConnectionSetupFailed, err)
+		return nil, err
 	}
 	defer db.Close()
 
 	// Test the connection using a derived context with timeout
-	pingCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	pingCtx, cancel := context.WithTimeout(ctx, cm.config.ConnectTimeout)
 	defer cancel()
 
 	if err := db.PingContext(pingCtx); err != nil {
+		// A destination denied by the egress policy is a request error rather than a failed connection
+		var denied *egress.DeniedError
+		if errors.As(err, &denied) {
+			return nil, fmt.Errorf("%w: %w", ErrConnectionFailed, denied)
+		}
 		return &ConnectResult{
 			Success: false,
 			Error:   fmt.Errorf("%w: %w", ErrConnectionFailed, err).Error(),
@@ -1,17 +1,35 @@
 package postgresql
 
 import (
+	"database/sql"
+	"fmt"
 	"sync"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/eyadmba/malleable-gremlin/services/egress"
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
 )
 
+// Config holds the timeouts and egress policy used by the ConnectionManager
+type Config struct {
+	ConnectTimeout   time.Duration
+	QueryPingTimeout time.Duration
+	// Egress restricts the database hosts that may be dialed; nil allows every host
+	Egress *egress.Guard
+}
+
 type ConnectionManager struct {
 	connections map[string]string
 	mu          sync.RWMutex
+	config      Config
 }
 
-func NewConnectionManager() *ConnectionManager {
+func NewConnectionManager(config Config) *ConnectionManager {
 	return &ConnectionManager{
 		connections: make(map[string]string),
+		config:      config,
 	}
 }
 
@@ -20,4 +38,17 @@ func (cm *ConnectionManager) Close() {
 	defer cm.mu.Unlock()
 
 	cm.connections = make(map[string]string)
+	metrics.PostgresStoredConnections.Set(0)
+}
+
+// open creates a connection pool for connStr whose connections are dialed through the egress guard
+func (cm *ConnectionManager) open(connStr string) (*sql.DB, error) {
+	connector, err := pq.NewConnector(connStr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrConnectionSetupFailed, err)
+	}
+	if cm.config.Egress != nil {
+		connector.Dialer(cm.config.Egress)
+	}
+	return sql.OpenDB(connector), nil
 }
@@ -7,8 +7,6 @@ import (
 	"time"
 )
 
-var queryPingTimeout = 5 * time.Second // Define a timeout for ping within ExecuteQuery
-
 type ExecuteQueryArgument struct {
 	ConnectionStringID string `json:"connectionStringId"`
 	ConnectionString   string `json:"connectionString"`
@@ -50,14 +48,14 @@ func (cm *ConnectionManager) ExecuteQuery(ctx context.Context, req *ExecuteQuery
 	}
 
 	// Open a new connection
-	db, err = sql.Open("postgres", connStr)
+	db, err = cm.open(connStr)
 	if err != nil {
-		return nil, fmt.Errorf("%w: %w", ErrConnectionSetupFailed, err)
+		return nil, err
 	}
 	defer db.Close()
 
 	// Ping the database using derived context with timeout
-	ctxPing, cancelPing := context.WithTimeout(ctx, queryPingTimeout)
+	ctxPing, cancelPing := context.WithTimeout(ctx, cm.config.QueryPingTimeout)
 	defer cancelPing()
 	if err := db.PingContext(ctxPing); err != nil {
 		return nil, fmt.Errorf("%w: %w", ErrConnectionFailed, err)
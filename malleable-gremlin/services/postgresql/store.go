@@ -3,6 +3,8 @@ package postgresql
 import (
 	"fmt"
 	"time"
+
+	"github.com/eyadmba/malleable-gremlin/services/metrics"
 )
 
 type StoreConnectionStringResult struct {
@@ -15,6 +17,7 @@ func (cm *ConnectionManager) StoreConnectionString(connStr string) *StoreConnect
 
 	cm.mu.Lock()
 	cm.connections[id] = connStr
+	metrics.PostgresStoredConnections.Set(float64(len(cm.connections)))
 	cm.mu.Unlock()
 
 	return &StoreConnectionStringResult{ID: id}
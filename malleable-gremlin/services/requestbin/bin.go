@@ -0,0 +1,250 @@
+package requestbin
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/eyadmba/malleable-gremlin/services/shared"
+)
+
+var binNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Config holds the limits of the bins
+type Config struct {
+	DefaultCapacity int
+	MaxCapacity     int
+	MaxBodySize     int64
+}
+
+type CapturedRequest struct {
+	ID         string      `json:"id"`
+	ReceivedAt time.Time   `json:"received_at"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	RequestURI string      `json:"request_uri"`
+	Proto      string      `json:"proto"`
+	Host       string      `json:"host"`
+	RemoteAddr string      `json:"remote_addr"`
+	Headers    http.Header `json:"headers"`
+	// Body is plain text, or base64 when BodyEncoding is "base64" because it is not valid UTF-8
+	Body          string `json:"body"`
+	BodyEncoding  string `json:"body_encoding"`
+	BodySize      int64  `json:"body_size"`
+	BodyTruncated bool   `json:"body_truncated"`
+
+	rawBody []byte
+}
+
+type BinInfo struct {
+	Name      string    `json:"name"`
+	Capacity  int       `json:"capacity"`
+	CreatedAt time.Time `json:"created_at"`
+	Stored    int       `json:"stored"`
+	// Captured counts every request received, including those evicted from the ring buffer
+	Captured int64 `json:"captured"`
+}
+
+// bin keeps the most recent requests in a ring buffer
+type bin struct {
+	name      string
+	createdAt time.Time
+	requests  []*CapturedRequest
+	next      int
+	stored    int
+	captured  int64
+}
+
+// Manager holds the request bins
+type Manager struct {
+	mu     sync.RWMutex
+	bins   map[string]*bin
+	config Config
+}
+
+func NewManager(config Config) *Manager {
+	return &Manager{
+		bins:   make(map[string]*bin),
+		config: config,
+	}
+}
+
+// Create adds an empty bin keeping up to capacity requests; zero uses the default capacity
+func (m *Manager) Create(name string, capacity int) (*BinInfo, error) {
+	if !binNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("%w: '%s'", ErrInvalidBinName, name)
+	}
+	if capacity == 0 {
+		capacity = m.config.DefaultCapacity
+	}
+	if capacity < 0 || capacity > m.config.MaxCapacity {
+		return nil, fmt.Errorf("%w: must be between 1 and %d", ErrInvalidCapacity, m.config.MaxCapacity)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.bins[name]; exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrBinExists, name)
+	}
+
+	b := &bin{
+		name:      name,
+		createdAt: time.Now(),
+		requests:  make([]*CapturedRequest, capacity),
+	}
+	m.bins[name] = b
+	return b.info(), nil
+}
+
+// Delete removes a bin and everything captured in it
+func (m *Manager) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.bins[name]; !exists {
+		return fmt.Errorf("%w: '%s'", ErrBinNotFound, name)
+	}
+	delete(m.bins, name)
+	return nil
+}
+
+// List returns every bin ordered by name
+func (m *Manager) List() []*BinInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	infos := make([]*BinInfo, 0, len(m.bins))
+	for _, b := range m.bins {
+		infos = append(infos, b.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Capture stores r in the named bin, evicting the oldest request when the bin is full. path is the
+// part of the URL below the bin. Bodies larger than the configured maximum are truncated.
+func (m *Manager) Capture(name, path string, r *http.Request) (*CapturedRequest, error) {
+	m.mu.RLock()
+	_, exists := m.bins[name]
+	m.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrBinNotFound, name)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, m.config.MaxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	// Count the rest of the body without keeping it
+	rest, _ := io.Copy(io.Discard, r.Body)
+
+	captured := &CapturedRequest{
+		ID:         shared.NewID("req_"),
+		ReceivedAt: time.Now(),
+		Method:     r.Method,
+		Path:       "/" + path,
+		RequestURI: r.RequestURI,
+		Proto:      r.Proto,
+		Host:       r.Host,
+		RemoteAddr: r.RemoteAddr,
+		Headers:    r.Header.Clone(),
+		BodySize:   int64(len(body)) + rest,
+	}
+	if int64(len(body)) > m.config.MaxBodySize {
+		body = body[:m.config.MaxBodySize]
+		captured.BodyTruncated = true
+	}
+	captured.rawBody = body
+	captured.Body, captured.BodyEncoding = encodeBody(body)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// The bin may have been deleted while the body was read
+	b, exists := m.bins[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrBinNotFound, name)
+	}
+	b.requests[b.next] = captured
+	b.next = (b.next + 1) % len(b.requests)
+	b.stored = min(b.stored+1, len(b.requests))
+	b.captured++
+
+	return captured, nil
+}
+
+// Requests returns the requests stored in a bin, most recent first
+func (m *Manager) Requests(name string) ([]*CapturedRequest, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	b, exists := m.bins[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrBinNotFound, name)
+	}
+	return b.newestFirst(), nil
+}
+
+// Get returns one captured request
+func (m *Manager) Get(name, id string) (*CapturedRequest, error) {
+	requests, err := m.Requests(name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, captured := range requests {
+		if captured.ID == id {
+			return captured, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: '%s' in bin '%s'", ErrRequestNotFound, id, name)
+}
+
+// Clear removes every stored request from a bin
+func (m *Manager) Clear(name string) (*BinInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.bins[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrBinNotFound, name)
+	}
+	b.requests = make([]*CapturedRequest, len(b.requests))
+	b.next = 0
+	b.stored = 0
+	return b.info(), nil
+}
+
+func (b *bin) info() *BinInfo {
+	return &BinInfo{
+		Name:      b.name,
+		Capacity:  len(b.requests),
+		CreatedAt: b.createdAt,
+		Stored:    b.stored,
+		Captured:  b.captured,
+	}
+}
+
+func (b *bin) newestFirst() []*CapturedRequest {
+	requests := make([]*CapturedRequest, 0, b.stored)
+	for i := 1; i <= b.stored; i++ {
+		idx := (b.next - i + len(b.requests)) % len(b.requests)
+		requests = append(requests, b.requests[idx])
+	}
+	return requests
+}
+
+// encodeBody returns the body as text, or as base64 when it is not valid UTF-8
+func encodeBody(body []byte) (string, string) {
+	if utf8.Valid(body) {
+		return string(body), "text"
+	}
+	return base64.StdEncoding.EncodeToString(body), "base64"
+}
@@ -0,0 +1,26 @@
+package requestbin
+
+import "errors"
+
+var (
+	// ErrInvalidBinName indicates that a bin name is empty, too long or contains characters other than letters, digits, '-' and '_'.
+	ErrInvalidBinName = errors.New("invalid bin name")
+
+	// ErrInvalidCapacity indicates that a bin capacity is not positive or exceeds the configured maximum.
+	ErrInvalidCapacity = errors.New("invalid bin capacity")
+
+	// ErrBinExists indicates that a bin with the same name already exists.
+	ErrBinExists = errors.New("bin already exists")
+
+	// ErrBinNotFound indicates that no bin exists with the given name.
+	ErrBinNotFound = errors.New("bin not found")
+
+	// ErrRequestNotFound indicates that the captured request is not (or no longer) in the bin.
+	ErrRequestNotFound = errors.New("captured request not found")
+
+	// ErrReplayUnsupported indicates that a captured request cannot be replayed as is (e.g. its body was truncated).
+	ErrReplayUnsupported = errors.New("captured request cannot be replayed")
+
+	// ErrInvalidReplay indicates that a replay argument is invalid (e.g. its URL is not absolute).
+	ErrInvalidReplay = errors.New("invalid replay")
+)
@@ -0,0 +1,99 @@
+package requestbin
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/eyadmba/malleable-gremlin/services/httpsender"
+)
+
+// hopHeaders are not forwarded when replaying since they describe the original connection
+var hopHeaders = map[string]bool{
+	"Connection":          true,
+	"Content-Length":      true,
+	"Host":                true,
+	"Keep-Alive":          true,
+	"Proxy-Authorization": true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+type ReplayArgument struct {
+	// URL is where the captured request is sent
+	URL string `json:"url"`
+	// PreservePath appends the captured path and query below the bin to URL
+	PreservePath bool `json:"preserve_path"`
+	// Headers are added to, or replace, the captured headers
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type ReplayResult struct {
+	Request  *httpsender.SendArgument `json:"request"`
+	Response *httpsender.SendResult   `json:"response"`
+}
+
+// Replay sends a captured request to another URL with the same method, headers and body.
+// The send is abandoned when ctx is done.
+func (m *Manager) Replay(ctx context.Context, name, id string, arg *ReplayArgument) (*ReplayResult, error) {
+	captured, err := m.Get(name, id)
+	if err != nil {
+		return nil, err
+	}
+
+	send, err := captured.sendArgument(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := httpsender.SendContext(ctx, send)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayResult{Request: send, Response: response}, nil
+}
+
+// sendArgument turns the captured request into an httpsender request
+func (c *CapturedRequest) sendArgument(arg *ReplayArgument) (*httpsender.SendArgument, error) {
+	if c.BodyTruncated {
+		return nil, fmt.Errorf("%w: body was truncated at %d bytes", ErrReplayUnsupported, len(c.rawBody))
+	}
+
+	target, err := url.Parse(arg.URL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("%w: url '%s' must be absolute", ErrInvalidReplay, arg.URL)
+	}
+	if arg.PreservePath {
+		target.Path = strings.TrimSuffix(target.Path, "/") + c.Path
+		if _, query, ok := strings.Cut(c.RequestURI, "?"); ok {
+			target.RawQuery = query
+		}
+	}
+
+	headers := make(map[string]string)
+	for k, v := range c.Headers {
+		if len(v) > 0 && !hopHeaders[http.CanonicalHeaderKey(k)] {
+			headers[k] = strings.Join(v, ", ")
+		}
+	}
+	for k, v := range arg.Headers {
+		headers[http.CanonicalHeaderKey(k)] = v
+	}
+
+	send := &httpsender.SendArgument{
+		URL:     target.String(),
+		Method:  c.Method,
+		Headers: headers,
+	}
+	if len(c.rawBody) > 0 {
+		// The body is replayed byte for byte
+		send.Body = base64.StdEncoding.EncodeToString(c.rawBody)
+		send.BodyMode = httpsender.BodyModeBase64
+	}
+	return send, nil
+}
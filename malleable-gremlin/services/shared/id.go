@@ -0,0 +1,15 @@
+package shared
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// NewID returns prefix followed by 16 random hex digits, e.g. "job_9f86d081884c7d65".
+// Unlike a timestamp it stays unique when several IDs are created in the same clock tick.
+func NewID(prefix string) string {
+	b := make([]byte, 8)
+	// crypto/rand.Read never fails on supported platforms
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}